@@ -0,0 +1,165 @@
+package analysis
+
+import (
+	"fmt"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/diagnostic"
+	"sort"
+)
+
+// Name of a binding that's deliberately unused, so never warned about
+const BLANK = "_"
+
+// Names of the lints Check runs, each reporting warnings that can be turned off with WithDisabled
+const (
+	UNUSED             = "unused"             // let and const bindings inside blocks that are never referred to
+	UNREACHABLE        = "unreachable"        // Statements following a return, break, or continue in the same block
+	CONSTANT_CONDITION = "constant-condition" // Conditions made only of literals, so always choosing the same branch
+)
+
+// Every lint, in the order they're documented
+var Lints = []string{UNUSED, UNREACHABLE, CONSTANT_CONDITION}
+
+// Configures optional analysis behaviour when passed to Check
+type Option func(*config)
+
+type config struct {
+	predeclared map[string]bool // Names bound before the program begins, so never reported as undefined
+	disabled    map[string]bool // Lints whose warnings aren't reported
+}
+
+// Treat names as bound before the program begins, e.g., builtins provided by the host or the top-level
+// bindings of imported modules
+func WithPredeclared(names ...string) Option {
+	return func(c *config) {
+		for _, name := range names {
+			c.predeclared[name] = true
+		}
+	}
+}
+
+// Don't report the warnings of the named lints. Names that aren't in Lints are ignored
+func WithDisabled(lints ...string) Option {
+	return func(c *config) {
+		for _, lint := range lints {
+			c.disabled[lint] = true
+		}
+	}
+}
+
+// Analyse program without running it, reporting problems in source order. Errors are reported for
+// identifiers that aren't bound in any enclosing scope and for let and const statements that bind a name
+// already bound in the same scope. Warnings are reported by each of the Lints, with the lint's name as their
+// code. Top-level bindings aren't reported as unused, as modules importing the program may refer to them
+func Check(program *ast.Program, options ...Option) []diagnostic.Diagnostic {
+	c := &config{predeclared: make(map[string]bool), disabled: make(map[string]bool)}
+	for _, option := range options {
+		option(c)
+	}
+
+	resolution := Resolve(program)
+
+	used := make(map[*ast.Identifier]bool)
+	for identifier, definition := range resolution.Definitions {
+		if identifier != definition {
+			used[definition] = true
+		}
+	}
+
+	topLevel := make(map[ast.Statement]bool)
+	for _, statement := range program.Statements {
+		topLevel[statement] = true
+	}
+
+	var diagnostics []diagnostic.Diagnostic
+	fail := func(node ast.Node, message string) {
+		diagnostics = append(diagnostics, diagnostic.Diagnostic{
+			Pos:      node.Pos(),
+			End:      node.End(),
+			Severity: diagnostic.ERROR,
+			Message:  message,
+		})
+	}
+	warn := func(node ast.Node, lint string, message string) {
+		if c.disabled[lint] {
+			return
+		}
+		diagnostics = append(diagnostics, diagnostic.Diagnostic{
+			Pos:      node.Pos(),
+			End:      node.End(),
+			Severity: diagnostic.WARNING,
+			Code:     lint,
+			Message:  message,
+		})
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			if unreachable, jump := afterJump(node.Statements); unreachable != nil {
+				warn(unreachable, UNREACHABLE, fmt.Sprintf("Unreachable statement. Follows a %s in the same block", jump.TokenLiteral()))
+			}
+		case *ast.BlockStatement:
+			if unreachable, jump := afterJump(node.Statements); unreachable != nil {
+				warn(unreachable, UNREACHABLE, fmt.Sprintf("Unreachable statement. Follows a %s in the same block", jump.TokenLiteral()))
+			}
+		case *ast.TernaryExpression:
+			if constant(node.Condition) {
+				warn(node.Condition, CONSTANT_CONDITION, "Constant condition. Made only of literals, so always chooses the same branch")
+			}
+		case *ast.LetStatement:
+			if node.Name == nil {
+				break
+			}
+			if earlier, ok := resolution.Redeclared[node.Name]; ok {
+				fail(node.Name, fmt.Sprintf("Duplicate binding %s. Already bound at %s in the same scope", node.Name.Value, earlier.Pos()))
+			}
+			if !topLevel[node] && !used[node.Name] && node.Name.Value != BLANK {
+				warn(node.Name, UNUSED, fmt.Sprintf("Unused binding %s. Declared with %s but never referred to", node.Name.Value, node.TokenLiteral()))
+			}
+		case *ast.Identifier:
+			if _, ok := resolution.Definitions[node]; !ok && !c.predeclared[node.Value] {
+				fail(node, fmt.Sprintf("Undefined identifier %s. Not bound in any enclosing scope", node.Value))
+			}
+		}
+		return true
+	})
+
+	// Unreachable statements are found on visiting their block, before anything the block contains
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Pos.Offset < diagnostics[j].Pos.Offset
+	})
+
+	return diagnostics
+}
+
+// Find the first statement following a return, break, or continue in statements, if any, along with that jump
+func afterJump(statements []ast.Statement) (ast.Statement, ast.Statement) {
+	for i, statement := range statements[:max(len(statements)-1, 0)] {
+		switch statement.(type) {
+		case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+			return statements[i+1], statement
+		}
+	}
+	return nil, nil
+}
+
+// Report whether expression is made only of literals and operators applied to them, so always has the same value
+func constant(expression ast.Expression) bool {
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.NullLiteral:
+		return true
+	case *ast.InterpolatedString:
+		for _, part := range expression.Parts {
+			if !constant(part) {
+				return false
+			}
+		}
+		return true
+	case *ast.PrefixExpression:
+		return constant(expression.Right)
+	case *ast.InfixExpression:
+		return constant(expression.Left) && constant(expression.Right)
+	}
+	return false
+}
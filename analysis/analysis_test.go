@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []checkTest{
+		{"let x = 1; puts(x);", nil},
+		// Unused bindings
+		{"let x = 1;", nil},
+		{"for (i in 1..3) { let x = i; }", []string{"1:23: warning: Unused binding x. Declared with let but never referred to (unused)"}},
+		{"for (i in 1..3) { const x = i; puts(x); }", nil},
+		{"for (i in 1..3) { let _ = i; }", nil},
+		{"try { let x = 1; let y = x; } catch (e) {}", []string{"1:22: warning: Unused binding y. Declared with let but never referred to (unused)"}},
+		// Undefined identifiers
+		{"let x = y;", []string{"1:9: Undefined identifier y. Not bound in any enclosing scope"}},
+		{"let x = x;", []string{"1:9: Undefined identifier x. Not bound in any enclosing scope"}},
+		{"for (i in 1..3) { let x = i; } puts(x);", []string{
+			"1:23: warning: Unused binding x. Declared with let but never referred to (unused)",
+			"1:37: Undefined identifier x. Not bound in any enclosing scope",
+		}},
+		{"try { 1 } catch (e) { puts(e); } puts(e);", []string{"1:39: Undefined identifier e. Not bound in any enclosing scope"}},
+		{"pust(1);", []string{"1:1: Undefined identifier pust. Not bound in any enclosing scope"}},
+		// Duplicate bindings
+		{"let x = 1; let x = 2;", []string{"1:16: Duplicate binding x. Already bound at 1:5 in the same scope"}},
+		{"let x = 1; const x = x + 1;", []string{"1:18: Duplicate binding x. Already bound at 1:5 in the same scope"}},
+		{"let x = 1; for (i in 1..3) { let x = 2; let x = i; puts(x); }", []string{
+			"1:34: warning: Unused binding x. Declared with let but never referred to (unused)",
+			"1:45: Duplicate binding x. Already bound at 1:34 in the same scope",
+		}},
+		// Inner scopes may shadow outer bindings, including a loop's body shadowing its variable
+		{"let x = 1; for (i in 1..3) { let x = i; puts(x); }", nil},
+		{"for (x in 1..3) { let x = x; puts(x); }", nil},
+	}
+
+	checkDiagnostics(t, tests, WithPredeclared("puts"))
+}
+
+func TestLints(t *testing.T) {
+	tests := []checkTest{
+		// Unreachable statements
+		{"return 1; puts(2); puts(3);", []string{"1:11: warning: Unreachable statement. Follows a return in the same block (unreachable)"}},
+		{"for (i in 1..3) { puts(i); return; puts(i) }", []string{"1:36: warning: Unreachable statement. Follows a return in the same block (unreachable)"}},
+		{"switch (1) { case 1: return 1; puts(1) default: puts(2) }", []string{"1:32: warning: Unreachable statement. Follows a return in the same block (unreachable)"}},
+		{"for (i in 1..3) { puts(i); return }", nil},
+		{"for (i in 1..3) { break; puts(i) }", []string{"1:26: warning: Unreachable statement. Follows a break in the same block (unreachable)"}},
+		{"for (i in 1..3) { continue\n puts(i) }", []string{"2:2: warning: Unreachable statement. Follows a continue in the same block (unreachable)"}},
+		{"for (i in 1..3) { let x = i; return; puts(i) }", []string{
+			"1:23: warning: Unused binding x. Declared with let but never referred to (unused)",
+			"1:38: warning: Unreachable statement. Follows a return in the same block (unreachable)",
+		}},
+		// Constant conditions
+		{"let x = 1 < 2 ? 3 : 4;", []string{"1:9: warning: Constant condition. Made only of literals, so always chooses the same branch (constant-condition)"}},
+		{"let x = \"a${1}\" ? 3 : 4;", []string{"1:9: warning: Constant condition. Made only of literals, so always chooses the same branch (constant-condition)"}},
+		{"let y = 1; let x = y < 2 ? 3 : 4;", nil},
+	}
+
+	checkDiagnostics(t, tests, WithPredeclared("puts"))
+}
+
+func TestDisabledLints(t *testing.T) {
+	tests := []checkTest{
+		{"for (i in 1..3) { let x = 1 ? 2 : 3; return; puts(i) }", []string{"1:27: warning: Constant condition. Made only of literals, so always chooses the same branch (constant-condition)"}},
+		// Only lints can be disabled, not errors
+		{"missing;", []string{"1:1: Undefined identifier missing. Not bound in any enclosing scope"}},
+	}
+
+	checkDiagnostics(t, tests, WithPredeclared("puts"), WithDisabled(UNUSED, UNREACHABLE))
+}
+
+// Source to analyse along with the diagnostics expected, formatted with Error
+type checkTest struct {
+	input    string
+	expected []string
+}
+
+// Check that analysing each input reports the expected diagnostics
+func checkDiagnostics(t *testing.T, tests []checkTest, options ...Option) {
+	t.Helper()
+
+	for _, test := range tests {
+		p := parser.New(lexer.New(test.input))
+		program := p.ParseProgram()
+		if errors := p.Errors(); len(errors) > 0 {
+			t.Fatalf("Unexpected parse errors for %q: %v", test.input, errors)
+		}
+
+		diagnostics := Check(program, options...)
+		if len(diagnostics) != len(test.expected) {
+			t.Fatalf("Unexpected diagnostic count for %q. Expected %d; got %d: %v", test.input, len(test.expected), len(diagnostics), diagnostics)
+		}
+
+		for i, expected := range test.expected {
+			if actual := diagnostics[i].Error(); actual != expected {
+				t.Errorf("Unexpected diagnostic for %q. Expected %q; got %q", test.input, expected, actual)
+			}
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	p := parser.New(lexer.New("let x = 1; for (x in 1..x) { x; } x;"))
+	program := p.ParseProgram()
+	if errors := p.Errors(); len(errors) > 0 {
+		t.Fatalf("Unexpected parse errors: %v", errors)
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	loop := program.Statements[1].(*ast.ForStatement)
+	tests := []struct {
+		identifier *ast.Identifier
+		expected   *ast.Identifier
+	}{
+		{let.Name, let.Name},
+		{loop.Variable, loop.Variable},
+		{loop.Iterable.(*ast.InfixExpression).Right.(*ast.Identifier), let.Name},
+		{loop.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.Identifier), loop.Variable},
+		{program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.Identifier), let.Name},
+	}
+
+	resolution := Resolve(program)
+	for i, test := range tests {
+		if actual := resolution.Definitions[test.identifier]; actual != test.expected {
+			t.Errorf("tests[%d] - Unexpected definition. Expected %s at %s; got %v", i, test.expected, test.expected.Pos(), actual)
+		}
+	}
+
+	if binder := resolution.Binders[loop.Variable]; binder != loop {
+		t.Errorf("Unexpected binder. Expected the for statement; got %v", binder)
+	}
+}
@@ -0,0 +1,84 @@
+package analysis
+
+import "rowanlovejoy/monkey/ast"
+
+// The bindings a program's identifiers refer to, as found by Resolve
+type Resolution struct {
+	Definitions map[*ast.Identifier]*ast.Identifier // Binding each identifier refers to; bindings refer to themselves
+	Binders     map[*ast.Identifier]ast.Node        // Statement that introduces each binding
+	Redeclared  map[*ast.Identifier]*ast.Identifier // Bindings of a name already bound in the same scope, mapped to the earlier binding
+}
+
+// Find the binding each identifier in node refers to. Blocks open a scope, let and const bind from the end of
+// their statement, loop variables are bound for the loop's body, and catch parameters for the handler.
+// Identifiers that refer to no binding are left out of Definitions
+func Resolve(node ast.Node) *Resolution {
+	r := &Resolution{
+		Definitions: make(map[*ast.Identifier]*ast.Identifier),
+		Binders:     make(map[*ast.Identifier]ast.Node),
+		Redeclared:  make(map[*ast.Identifier]*ast.Identifier),
+	}
+	scopes := []map[string]*ast.Identifier{{}}
+
+	bind := func(name *ast.Identifier, binder ast.Node) {
+		if name == nil {
+			return
+		}
+		if earlier, ok := scopes[len(scopes)-1][name.Value]; ok {
+			r.Redeclared[name] = earlier
+		}
+		scopes[len(scopes)-1][name.Value] = name
+		r.Definitions[name] = name
+		r.Binders[name] = binder
+	}
+	push := func() {
+		scopes = append(scopes, map[string]*ast.Identifier{})
+	}
+	pop := func() {
+		scopes = scopes[:len(scopes)-1]
+	}
+
+	var walk func(node ast.Node)
+	walk = func(node ast.Node) {
+		ast.Inspect(node, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.LetStatement:
+				walk(node.Value)
+				bind(node.Name, node)
+				return false
+			case *ast.BlockStatement:
+				push()
+				for _, statement := range node.Statements {
+					walk(statement)
+				}
+				pop()
+				return false
+			case *ast.ForStatement:
+				walk(node.Iterable)
+				push()
+				bind(node.Variable, node)
+				walk(node.Body)
+				pop()
+				return false
+			case *ast.TryStatement:
+				walk(node.Body)
+				push()
+				bind(node.Parameter, node)
+				walk(node.Handler)
+				pop()
+				return false
+			case *ast.Identifier:
+				for i := len(scopes) - 1; i >= 0; i-- {
+					if definition, ok := scopes[i][node.Value]; ok {
+						r.Definitions[node] = definition
+						break
+					}
+				}
+			}
+			return true
+		})
+	}
+	walk(node)
+
+	return r
+}
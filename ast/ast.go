@@ -1,16 +1,23 @@
 package ast
 
 import (
-	"bytes"
 	"rowanlovejoy/monkey/token"
+	"strings"
 )
 
 // String returned when calling TokenLiteral on a nil receiver
 const NIL_TOKEN_LITERAL = "<nil>"
 
+// Marker beginning each line of a doc comment
+const DOC_COMMENT_PREFIX = "///"
+
+// Every node spans a range of the source from Pos up to End. Parentheses that only group an expression
+// aren't recorded in the tree, so they fall outside the span of the expression they enclose
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // Position of the node's first char in the source
+	End() token.Position // Position immediately after the node's last char
 }
 
 // Represents a unit of code that doesn't produce value, e.g., 'let x = 5';
@@ -27,6 +34,29 @@ type Expression interface {
 	expressionNode()
 }
 
+// A node that writes its String form into a builder shared with the rest of the tree, so that stringifying a large
+// program fills one builder rather than allocating a string for every node
+type writer interface {
+	Node
+	writeTo(out *strings.Builder)
+}
+
+// Write a node's String form to out. Leaves, whose String returns a string they already hold, and nodes from
+// outside the package, e.g., those added by parser extensions, are written with String
+func writeNode(out *strings.Builder, node Node) {
+	if w, ok := node.(writer); ok {
+		w.writeTo(out)
+		return
+	}
+	out.WriteString(node.String())
+}
+
+func stringOf(node writer) string {
+	var out strings.Builder
+	node.writeTo(&out)
+	return out.String()
+}
+
 // The root node of every AST produced by the parser
 type Program struct {
 	Statements []Statement
@@ -41,20 +71,37 @@ func (p *Program) TokenLiteral() string {
 	}
 } // Satisfies Node interface
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+} // Satisfies Node interface
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+} // Satisfies Node interface
+
 func (p *Program) String() string {
-	var out bytes.Buffer
+	return stringOf(p)
+} // Satisfies Node interface
 
+func (p *Program) writeTo(out *strings.Builder) {
 	for _, s := range p.Statements {
-		out.WriteString(s.String())
+		writeNode(out, s)
 	}
+}
 
-	return out.String()
-} // Satisfies Node interface
-
+// Binds a name to a value, e.g., 'let x = 5;'. Also represents 'const x = 5;', whose binding can't be reassigned
 type LetStatement struct {
-	Token token.Token // token.LET
+	Token token.Token // token.LET or token.CONST
 	Name  *Identifier // Identifier being bound to
 	Value Expression  // Expression returning the value to be bound
+
+	// token.DOCCOMMENT lines immediately preceding the statement, if any. They aren't part of its span
+	Doc []token.Token
 }
 
 func (ls *LetStatement) statementNode() {} // Satisfies Statement interface
@@ -64,22 +111,56 @@ func (ls *LetStatement) TokenLiteral() string {
 	}
 	return ls.Token.Literal
 } // Satisfies Node interface
+func (ls *LetStatement) Pos() token.Position {
+	if ls == nil {
+		return token.Position{}
+	}
+	return ls.Token.Pos
+} // Satisfies Node interface
+func (ls *LetStatement) End() token.Position {
+	if ls == nil {
+		return token.Position{}
+	}
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.Name.End()
+} // Satisfies Node interface
+
+// Report whether the binding was declared with const and so must not be reassigned
+func (ls *LetStatement) Constant() bool {
+	return ls.Token.Type == token.CONST
+}
+
+// Get the text of the statement's doc comment without its /// markers, one line per line of the comment.
+// Returns an empty string if there's no doc comment
+func (ls *LetStatement) DocText() string {
+	var out strings.Builder
+
+	for _, line := range ls.Doc {
+		text := strings.TrimPrefix(line.Literal, DOC_COMMENT_PREFIX)
+		out.WriteString(strings.TrimPrefix(text, " "))
+		out.WriteString("\n")
+	}
+
+	return out.String()
+}
 
 func (ls *LetStatement) String() string {
-	var out bytes.Buffer
+	return stringOf(ls)
+} // Satisfies Node interface
 
+func (ls *LetStatement) writeTo(out *strings.Builder) {
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	writeNode(out, ls.Name)
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
-		out.WriteString(ls.Value.String())
+		writeNode(out, ls.Value)
 	}
 
 	out.WriteString(";")
-
-	return out.String()
-} // Satisfies Node interface
+}
 
 type ReturnStatement struct {
 	Token       token.Token // token.RETURN
@@ -93,20 +174,35 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	}
 	return rs.Token.Literal
 } // Satisfies Node interface
+func (rs *ReturnStatement) Pos() token.Position {
+	if rs == nil {
+		return token.Position{}
+	}
+	return rs.Token.Pos
+} // Satisfies Node interface
+func (rs *ReturnStatement) End() token.Position {
+	if rs == nil {
+		return token.Position{}
+	}
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+} // Satisfies Node interface
 
 func (rs *ReturnStatement) String() string {
-	var out bytes.Buffer
+	return stringOf(rs)
+} // Satisfies Node interface
 
+func (rs *ReturnStatement) writeTo(out *strings.Builder) {
 	out.WriteString(rs.TokenLiteral() + " ")
 
 	if rs.ReturnValue != nil {
-		out.WriteString(rs.ReturnValue.String())
+		writeNode(out, rs.ReturnValue)
 	}
 
 	out.WriteString(";")
-
-	return out.String()
-} // Satisfies Node interface
+}
 
 type ExpressionStatement struct {
 	Token      token.Token // First token in the expression
@@ -120,12 +216,33 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	}
 	return es.Token.Literal
 } // Satisfies Node interface
+func (es *ExpressionStatement) Pos() token.Position {
+	if es == nil {
+		return token.Position{}
+	}
+	if es.Expression != nil {
+		return es.Expression.Pos()
+	}
+	return es.Token.Pos
+} // Satisfies Node interface
+func (es *ExpressionStatement) End() token.Position {
+	if es == nil {
+		return token.Position{}
+	}
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+} // Satisfies Node interface
 
 func (es *ExpressionStatement) String() string {
+	return stringOf(es)
+}
+
+func (es *ExpressionStatement) writeTo(out *strings.Builder) {
 	if es.Expression != nil {
-		return es.Expression.String()
+		writeNode(out, es.Expression)
 	}
-	return ""
 }
 
 // A name to which a value has been bound
@@ -141,6 +258,18 @@ func (i *Identifier) TokenLiteral() string {
 	}
 	return i.Token.Literal
 } // Satisfies Node interface
+func (i *Identifier) Pos() token.Position {
+	if i == nil {
+		return token.Position{}
+	}
+	return i.Token.Pos
+} // Satisfies Node interface
+func (i *Identifier) End() token.Position {
+	if i == nil {
+		return token.Position{}
+	}
+	return i.Token.End
+} // Satisfies Node interface
 
 func (i *Identifier) String() string {
 	return i.Value
@@ -158,13 +287,167 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	}
 	return il.Token.Literal
 } // Satisfies Node interface
+func (il *IntegerLiteral) Pos() token.Position {
+	if il == nil {
+		return token.Position{}
+	}
+	return il.Token.Pos
+} // Satisfies Node interface
+func (il *IntegerLiteral) End() token.Position {
+	if il == nil {
+		return token.Position{}
+	}
+	return il.Token.End
+} // Satisfies Node interface
 func (il *IntegerLiteral) String() string {
 	if il == nil {
 		return NIL_TOKEN_LITERAL
 	}
+	// Only folding produces a negative value, which is parenthesised like the prefix expression it replaced, so
+	// that, e.g., '-(-9223372036854775808)' doesn't print as '--9223372036854775808'
+	if il.Value < 0 {
+		return "(" + il.Token.Literal + ")"
+	}
 	return il.Token.Literal
 } // Satisfies Node interface
 
+type StringLiteral struct {
+	Token token.Token
+	Value string // The string's contents, without quotes
+}
+
+func (sl *StringLiteral) expressionNode() {} // Satisfies Expression interface
+func (sl *StringLiteral) TokenLiteral() string {
+	if sl == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return sl.Token.Literal
+} // Satisfies Node interface
+func (sl *StringLiteral) Pos() token.Position {
+	if sl == nil {
+		return token.Position{}
+	}
+	return sl.Token.Pos
+} // Satisfies Node interface
+func (sl *StringLiteral) End() token.Position {
+	if sl == nil {
+		return token.Position{}
+	}
+	return sl.Token.End
+} // Satisfies Node interface
+func (sl *StringLiteral) String() string {
+	if sl == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return `"` + EscapeString(sl.Value) + `"`
+} // Satisfies Node interface
+
+// Escape the text of a string so that it's lexed back as the same text: each " and the $ of each ${ is escaped, as
+// is each backslash that would otherwise escape the char after it or the string's closing "
+func EscapeString(text string) string {
+	if !strings.ContainsAny(text, `"$\`) {
+		return text
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(text); i++ {
+		next := byte(0)
+		if i+1 < len(text) {
+			next = text[i+1]
+		}
+
+		switch {
+		case text[i] == '"',
+			text[i] == '$' && next == '{',
+			text[i] == '\\' && (next == 0 || strings.IndexByte(`"$\`, next) >= 0):
+			out.WriteByte('\\')
+		}
+		out.WriteByte(text[i])
+	}
+
+	return out.String()
+}
+
+// A string with embedded expressions, e.g., "total: ${x + y}"
+type InterpolatedString struct {
+	Token token.Token  // token.STRINGHEAD
+	Parts []Expression // Alternating *StringLiteral text and embedded expressions, starting and ending with text
+}
+
+func (is *InterpolatedString) expressionNode() {} // Satisfies Expression interface
+func (is *InterpolatedString) TokenLiteral() string {
+	if is == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return is.Token.Literal
+} // Satisfies Node interface
+func (is *InterpolatedString) Pos() token.Position {
+	if is == nil {
+		return token.Position{}
+	}
+	return is.Token.Pos
+} // Satisfies Node interface
+func (is *InterpolatedString) End() token.Position {
+	if is == nil {
+		return token.Position{}
+	}
+	if len(is.Parts) > 0 {
+		return is.Parts[len(is.Parts)-1].End()
+	}
+	return is.Token.End
+} // Satisfies Node interface
+
+func (is *InterpolatedString) String() string {
+	return stringOf(is)
+} // Satisfies Node interface
+
+func (is *InterpolatedString) writeTo(out *strings.Builder) {
+	out.WriteString(`"`)
+
+	for _, part := range is.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			out.WriteString(EscapeString(text.Value))
+		} else {
+			out.WriteString("${")
+			writeNode(out, part)
+			out.WriteString("}")
+		}
+	}
+
+	out.WriteString(`"`)
+}
+
+// The absence of a value, written 'null'
+type NullLiteral struct {
+	Token token.Token // token.NULL
+}
+
+func (nl *NullLiteral) expressionNode() {} // Satisfies Expression interface
+func (nl *NullLiteral) TokenLiteral() string {
+	if nl == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return nl.Token.Literal
+} // Satisfies Node interface
+func (nl *NullLiteral) Pos() token.Position {
+	if nl == nil {
+		return token.Position{}
+	}
+	return nl.Token.Pos
+} // Satisfies Node interface
+func (nl *NullLiteral) End() token.Position {
+	if nl == nil {
+		return token.Position{}
+	}
+	return nl.Token.End
+} // Satisfies Node interface
+func (nl *NullLiteral) String() string {
+	if nl == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return nl.Token.Literal
+} // Satisfies Node interface
+
 type PrefixExpression struct {
 	Token    token.Token // Prefix operator token, e.g., !, -
 	Operator string      // ! or -
@@ -178,15 +461,27 @@ func (pe *PrefixExpression) TokenLiteral() string {
 	}
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Pos() token.Position {
+	if pe == nil {
+		return token.Position{}
+	}
+	return pe.Token.Pos
+}
+func (pe *PrefixExpression) End() token.Position {
+	if pe == nil {
+		return token.Position{}
+	}
+	return pe.Right.End()
+}
 func (pe *PrefixExpression) String() string {
-	var out bytes.Buffer
+	return stringOf(pe)
+}
 
+func (pe *PrefixExpression) writeTo(out *strings.Builder) {
 	out.WriteString("(")
 	out.WriteString(pe.Operator)
-	out.WriteString(pe.Right.String())
+	writeNode(out, pe.Right)
 	out.WriteString(")")
-
-	return out.String()
 }
 
 type InfixExpression struct {
@@ -203,14 +498,581 @@ func (ie *InfixExpression) TokenLiteral() string {
 	}
 	return ie.Token.Literal
 }
+func (ie *InfixExpression) Pos() token.Position {
+	if ie == nil {
+		return token.Position{}
+	}
+	return ie.Left.Pos()
+}
+func (ie *InfixExpression) End() token.Position {
+	if ie == nil {
+		return token.Position{}
+	}
+	return ie.Right.End()
+}
 func (ie *InfixExpression) String() string {
-	var out bytes.Buffer
+	return stringOf(ie)
+}
 
+func (ie *InfixExpression) writeTo(out *strings.Builder) {
 	out.WriteString("(")
-	out.WriteString(ie.Left.String())
+	writeNode(out, ie.Left)
 	out.WriteString(" " + ie.Operator + " ")
-	out.WriteString(ie.Right.String())
+	writeNode(out, ie.Right)
 	out.WriteString(")")
+}
 
-	return out.String()
+// Loads another source file as a module, e.g., 'import "lib/strings";'
+type ImportStatement struct {
+	Token token.Token    // token.IMPORT
+	Path  *StringLiteral // Path of the module, relative to the importing file
+}
+
+func (imp *ImportStatement) statementNode() {} // Satisfies Statement interface
+func (imp *ImportStatement) TokenLiteral() string {
+	if imp == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return imp.Token.Literal
+} // Satisfies Node interface
+func (imp *ImportStatement) Pos() token.Position {
+	if imp == nil {
+		return token.Position{}
+	}
+	return imp.Token.Pos
+} // Satisfies Node interface
+func (imp *ImportStatement) End() token.Position {
+	if imp == nil {
+		return token.Position{}
+	}
+	return imp.Path.End()
+} // Satisfies Node interface
+
+func (imp *ImportStatement) String() string {
+	return stringOf(imp)
+} // Satisfies Node interface
+
+func (imp *ImportStatement) writeTo(out *strings.Builder) {
+	out.WriteString(imp.TokenLiteral() + " ")
+	writeNode(out, imp.Path)
+	out.WriteString(";")
+}
+
+// A sequence of statements enclosed in braces, e.g., the body of a loop
+type BlockStatement struct {
+	Token      token.Token // token.LBRACE, or token.COLON for the body of a switch case
+	Statements []Statement
+	RBrace     token.Token // Closing }; zero for the body of a switch case, which ends at its last statement
+}
+
+func (bs *BlockStatement) statementNode() {} // Satisfies Statement interface
+func (bs *BlockStatement) TokenLiteral() string {
+	if bs == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return bs.Token.Literal
+} // Satisfies Node interface
+func (bs *BlockStatement) Pos() token.Position {
+	if bs == nil {
+		return token.Position{}
+	}
+	return bs.Token.Pos
+} // Satisfies Node interface
+func (bs *BlockStatement) End() token.Position {
+	if bs == nil {
+		return token.Position{}
+	}
+	if bs.RBrace.Type == token.RBRACE {
+		return bs.RBrace.End
+	}
+	if len(bs.Statements) > 0 {
+		return bs.Statements[len(bs.Statements)-1].End()
+	}
+	return bs.Token.End
+} // Satisfies Node interface
+
+func (bs *BlockStatement) String() string {
+	return stringOf(bs)
+} // Satisfies Node interface
+
+func (bs *BlockStatement) writeTo(out *strings.Builder) {
+	out.WriteString("{")
+
+	for _, s := range bs.Statements {
+		writeNode(out, s)
+	}
+
+	out.WriteString("}")
+}
+
+// Loop running its body once per element of an iterable, e.g., 'for (x in xs) { ... }'
+type ForStatement struct {
+	Token    token.Token     // token.FOR
+	Variable *Identifier     // Identifier bound to each element in turn
+	Iterable Expression      // Expression producing the elements to iterate over
+	Body     *BlockStatement // Statements run for each element
+}
+
+func (fs *ForStatement) statementNode() {} // Satisfies Statement interface
+func (fs *ForStatement) TokenLiteral() string {
+	if fs == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return fs.Token.Literal
+} // Satisfies Node interface
+func (fs *ForStatement) Pos() token.Position {
+	if fs == nil {
+		return token.Position{}
+	}
+	return fs.Token.Pos
+} // Satisfies Node interface
+func (fs *ForStatement) End() token.Position {
+	if fs == nil {
+		return token.Position{}
+	}
+	return fs.Body.End()
+} // Satisfies Node interface
+
+func (fs *ForStatement) String() string {
+	return stringOf(fs)
+} // Satisfies Node interface
+
+func (fs *ForStatement) writeTo(out *strings.Builder) {
+	out.WriteString(fs.TokenLiteral() + " (")
+	writeNode(out, fs.Variable)
+	out.WriteString(" in ")
+	writeNode(out, fs.Iterable)
+	out.WriteString(") ")
+	writeNode(out, fs.Body)
+}
+
+// Ends the innermost enclosing loop, e.g., 'break'
+type BreakStatement struct {
+	Token token.Token // token.BREAK
+}
+
+func (bs *BreakStatement) statementNode() {} // Satisfies Statement interface
+func (bs *BreakStatement) TokenLiteral() string {
+	if bs == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return bs.Token.Literal
+} // Satisfies Node interface
+func (bs *BreakStatement) Pos() token.Position {
+	if bs == nil {
+		return token.Position{}
+	}
+	return bs.Token.Pos
+} // Satisfies Node interface
+func (bs *BreakStatement) End() token.Position {
+	if bs == nil {
+		return token.Position{}
+	}
+	return bs.Token.End
+} // Satisfies Node interface
+
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+} // Satisfies Node interface
+
+// Skips the rest of the body of the innermost enclosing loop, moving on to its next element, e.g., 'continue'
+type ContinueStatement struct {
+	Token token.Token // token.CONTINUE
+}
+
+func (cs *ContinueStatement) statementNode() {} // Satisfies Statement interface
+func (cs *ContinueStatement) TokenLiteral() string {
+	if cs == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return cs.Token.Literal
+} // Satisfies Node interface
+func (cs *ContinueStatement) Pos() token.Position {
+	if cs == nil {
+		return token.Position{}
+	}
+	return cs.Token.Pos
+} // Satisfies Node interface
+func (cs *ContinueStatement) End() token.Position {
+	if cs == nil {
+		return token.Position{}
+	}
+	return cs.Token.End
+} // Satisfies Node interface
+
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+} // Satisfies Node interface
+
+// Runs a block, recovering from any error it raises by running a handler, e.g.,
+// 'try { risky(); } catch (e) { log(e); }'
+type TryStatement struct {
+	Token     token.Token     // token.TRY
+	Body      *BlockStatement // Statements that may raise an error
+	Parameter *Identifier     // Identifier bound to the error within the handler
+	Handler   *BlockStatement // Statements run if the body raises an error
+}
+
+func (ts *TryStatement) statementNode() {} // Satisfies Statement interface
+func (ts *TryStatement) TokenLiteral() string {
+	if ts == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return ts.Token.Literal
+} // Satisfies Node interface
+func (ts *TryStatement) Pos() token.Position {
+	if ts == nil {
+		return token.Position{}
+	}
+	return ts.Token.Pos
+} // Satisfies Node interface
+func (ts *TryStatement) End() token.Position {
+	if ts == nil {
+		return token.Position{}
+	}
+	return ts.Handler.End()
+} // Satisfies Node interface
+
+func (ts *TryStatement) String() string {
+	return stringOf(ts)
+} // Satisfies Node interface
+
+func (ts *TryStatement) writeTo(out *strings.Builder) {
+	out.WriteString(ts.TokenLiteral() + " ")
+	writeNode(out, ts.Body)
+	out.WriteString(" catch (")
+	writeNode(out, ts.Parameter)
+	out.WriteString(") ")
+	writeNode(out, ts.Handler)
+}
+
+// Rebinds an existing name to a new value, e.g., 'x = x + 1', or updates it with a compound assignment operator,
+// e.g., 'x += 1'
+type AssignExpression struct {
+	Token    token.Token // token.ASSIGN, PLUSASSIGN, MINUSASSIGN, ASTERISKASSIGN, or SLASHASSIGN
+	Target   Expression  // Binding being updated, either an *Identifier or an *IndexExpression
+	Operator string      // =, or the compound assignment operator, e.g., +=
+	Value    Expression  // Expression returning the new value, or the right operand of a compound assignment
+}
+
+func (ae *AssignExpression) expressionNode() {} // Satisfies Expression interface
+func (ae *AssignExpression) TokenLiteral() string {
+	if ae == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return ae.Token.Literal
+} // Satisfies Node interface
+func (ae *AssignExpression) Pos() token.Position {
+	if ae == nil {
+		return token.Position{}
+	}
+	return ae.Target.Pos()
+} // Satisfies Node interface
+func (ae *AssignExpression) End() token.Position {
+	if ae == nil {
+		return token.Position{}
+	}
+	return ae.Value.End()
+} // Satisfies Node interface
+
+func (ae *AssignExpression) String() string {
+	return stringOf(ae)
+} // Satisfies Node interface
+
+func (ae *AssignExpression) writeTo(out *strings.Builder) {
+	out.WriteString("(")
+	writeNode(out, ae.Target)
+	out.WriteString(" " + ae.Operator + " ")
+	writeNode(out, ae.Value)
+	out.WriteString(")")
+}
+
+// Conditional producing one of two values, e.g., 'x > 0 ? x : -x'
+type TernaryExpression struct {
+	Token       token.Token // token.QUESTION
+	Condition   Expression
+	Consequence Expression // Produced if the condition is truthy
+	Alternative Expression // Produced otherwise
+}
+
+func (te *TernaryExpression) expressionNode() {} // Satisfies Expression interface
+func (te *TernaryExpression) TokenLiteral() string {
+	if te == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return te.Token.Literal
+} // Satisfies Node interface
+func (te *TernaryExpression) Pos() token.Position {
+	if te == nil {
+		return token.Position{}
+	}
+	return te.Condition.Pos()
+} // Satisfies Node interface
+func (te *TernaryExpression) End() token.Position {
+	if te == nil {
+		return token.Position{}
+	}
+	return te.Alternative.End()
+} // Satisfies Node interface
+
+func (te *TernaryExpression) String() string {
+	return stringOf(te)
+} // Satisfies Node interface
+
+func (te *TernaryExpression) writeTo(out *strings.Builder) {
+	out.WriteString("(")
+	writeNode(out, te.Condition)
+	out.WriteString(" ? ")
+	writeNode(out, te.Consequence)
+	out.WriteString(" : ")
+	writeNode(out, te.Alternative)
+	out.WriteString(")")
+}
+
+// Produces the value of the first case whose value equals the subject, e.g.,
+// 'switch (x) { case 1: "one"; default: "many" }'. Cases don't fall through
+type SwitchExpression struct {
+	Token   token.Token // token.SWITCH
+	Subject Expression  // Expression compared against each case's value
+	Cases   []*SwitchCase
+	RBrace  token.Token // Closing }
+}
+
+func (se *SwitchExpression) expressionNode() {} // Satisfies Expression interface
+func (se *SwitchExpression) TokenLiteral() string {
+	if se == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return se.Token.Literal
+} // Satisfies Node interface
+func (se *SwitchExpression) Pos() token.Position {
+	if se == nil {
+		return token.Position{}
+	}
+	return se.Token.Pos
+} // Satisfies Node interface
+func (se *SwitchExpression) End() token.Position {
+	if se == nil {
+		return token.Position{}
+	}
+	return se.RBrace.End
+} // Satisfies Node interface
+
+func (se *SwitchExpression) String() string {
+	return stringOf(se)
+} // Satisfies Node interface
+
+func (se *SwitchExpression) writeTo(out *strings.Builder) {
+	out.WriteString(se.TokenLiteral() + " (")
+	writeNode(out, se.Subject)
+	out.WriteString(") {")
+
+	for _, c := range se.Cases {
+		writeNode(out, c)
+	}
+
+	out.WriteString("}")
+}
+
+// A single branch of a switch expression. The default branch has no value
+type SwitchCase struct {
+	Token token.Token     // token.CASE or token.DEFAULT
+	Value Expression      // Value compared against the switch subject; nil for the default branch
+	Body  *BlockStatement // Statements following the case's colon
+}
+
+func (sc *SwitchCase) TokenLiteral() string {
+	if sc == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return sc.Token.Literal
+} // Satisfies Node interface
+func (sc *SwitchCase) Pos() token.Position {
+	if sc == nil {
+		return token.Position{}
+	}
+	return sc.Token.Pos
+} // Satisfies Node interface
+func (sc *SwitchCase) End() token.Position {
+	if sc == nil {
+		return token.Position{}
+	}
+	return sc.Body.End()
+} // Satisfies Node interface
+
+func (sc *SwitchCase) String() string {
+	return stringOf(sc)
+} // Satisfies Node interface
+
+func (sc *SwitchCase) writeTo(out *strings.Builder) {
+	out.WriteString(sc.TokenLiteral())
+
+	if sc.Value != nil {
+		out.WriteString(" ")
+		writeNode(out, sc.Value)
+	}
+
+	out.WriteString(": ")
+
+	for _, s := range sc.Body.Statements {
+		writeNode(out, s)
+	}
+}
+
+// Report whether this is the switch's default branch
+func (sc *SwitchCase) IsDefault() bool {
+	return sc.Token.Type == token.DEFAULT
+}
+
+// Looks up an element of a collection, e.g., 'person.name', which indexes person with the string key "name"
+type IndexExpression struct {
+	Token    token.Token // Token that began the index, either token.DOT or token.LBRACKET
+	Left     Expression  // Expression producing the collection
+	Index    Expression  // Expression producing the key or position
+	RBracket token.Token // Closing ]; zero for a dot index, e.g., 'person.name'
+}
+
+func (ie *IndexExpression) expressionNode() {} // Satisfies Expression interface
+func (ie *IndexExpression) TokenLiteral() string {
+	if ie == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return ie.Token.Literal
+} // Satisfies Node interface
+func (ie *IndexExpression) Pos() token.Position {
+	if ie == nil {
+		return token.Position{}
+	}
+	return ie.Left.Pos()
+} // Satisfies Node interface
+func (ie *IndexExpression) End() token.Position {
+	if ie == nil {
+		return token.Position{}
+	}
+	if ie.RBracket.Type == token.RBRACKET {
+		return ie.RBracket.End
+	}
+	return ie.Index.End()
+} // Satisfies Node interface
+
+func (ie *IndexExpression) String() string {
+	return stringOf(ie)
+} // Satisfies Node interface
+
+func (ie *IndexExpression) writeTo(out *strings.Builder) {
+	out.WriteString("(")
+	writeNode(out, ie.Left)
+	if key, ok := ie.Index.(*StringLiteral); ok && ie.Token.Type == token.DOT {
+		out.WriteString("." + key.Value)
+	} else {
+		out.WriteString("[")
+		writeNode(out, ie.Index)
+		out.WriteString("]")
+	}
+	out.WriteString(")")
+}
+
+// Takes a contiguous part of a collection, e.g., 'arr[1:3]'. Either bound may be omitted, e.g., 's[2:]'
+type SliceExpression struct {
+	Token    token.Token // token.LBRACKET
+	Left     Expression  // Expression producing the collection
+	Low      Expression  // Expression producing the position of the first element taken; nil if omitted
+	High     Expression  // Expression producing the position after the last element taken; nil if omitted
+	RBracket token.Token // Closing ]
+}
+
+func (se *SliceExpression) expressionNode() {} // Satisfies Expression interface
+func (se *SliceExpression) TokenLiteral() string {
+	if se == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return se.Token.Literal
+} // Satisfies Node interface
+func (se *SliceExpression) Pos() token.Position {
+	if se == nil {
+		return token.Position{}
+	}
+	return se.Left.Pos()
+} // Satisfies Node interface
+func (se *SliceExpression) End() token.Position {
+	if se == nil {
+		return token.Position{}
+	}
+	return se.RBracket.End
+} // Satisfies Node interface
+
+func (se *SliceExpression) String() string {
+	return stringOf(se)
+} // Satisfies Node interface
+
+func (se *SliceExpression) writeTo(out *strings.Builder) {
+	out.WriteString("(")
+	writeNode(out, se.Left)
+	out.WriteString("[")
+
+	if se.Low != nil {
+		writeNode(out, se.Low)
+	}
+
+	out.WriteString(":")
+
+	if se.High != nil {
+		writeNode(out, se.High)
+	}
+
+	out.WriteString("])")
+}
+
+// Calls a function with arguments, e.g., 'add(1, 2)'
+type CallExpression struct {
+	Token     token.Token // token.LPAREN, or token.PIPE for a call desugared from 'x |> f', which has no (
+	Function  Expression  // Expression producing the function to call, e.g., an *Identifier
+	Arguments []Expression
+	RParen    token.Token // Closing ); zero for a call desugared from 'x |> f', which has no parentheses
+}
+
+func (ce *CallExpression) expressionNode() {} // Satisfies Expression interface
+func (ce *CallExpression) TokenLiteral() string {
+	if ce == nil {
+		return NIL_TOKEN_LITERAL
+	}
+	return ce.Token.Literal
+} // Satisfies Node interface
+func (ce *CallExpression) Pos() token.Position {
+	if ce == nil {
+		return token.Position{}
+	}
+	// A call desugared from a pipeline begins with its first argument, e.g., x in 'x |> f(y)'
+	if len(ce.Arguments) > 0 && ce.Arguments[0].Pos().Offset < ce.Function.Pos().Offset {
+		return ce.Arguments[0].Pos()
+	}
+	return ce.Function.Pos()
+} // Satisfies Node interface
+func (ce *CallExpression) End() token.Position {
+	if ce == nil {
+		return token.Position{}
+	}
+	if ce.RParen.Type == token.RPAREN {
+		return ce.RParen.End
+	}
+	// A call desugared from 'x |> f' has no parentheses
+	return ce.Function.End()
+} // Satisfies Node interface
+
+func (ce *CallExpression) String() string {
+	return stringOf(ce)
+} // Satisfies Node interface
+
+func (ce *CallExpression) writeTo(out *strings.Builder) {
+	writeNode(out, ce.Function)
+	out.WriteString("(")
+
+	for i, a := range ce.Arguments {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		writeNode(out, a)
+	}
+
+	out.WriteString(")")
 }
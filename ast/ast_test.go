@@ -2,6 +2,7 @@ package ast
 
 import (
 	"rowanlovejoy/monkey/token"
+	"strconv"
 	"testing"
 )
 
@@ -37,3 +38,78 @@ func TestString(t *testing.T) {
 		t.Errorf("Unexpected program string. Expected %q; got %q", expectedString, programString)
 	}
 }
+
+// An expression from outside the package, as a parser extension might add, which only has String
+type customExpression struct {
+	Identifier
+}
+
+func (ce *customExpression) String() string {
+	return "custom"
+}
+
+func TestStringWithCustomNode(t *testing.T) {
+	expression := &InfixExpression{
+		Operator: "+",
+		Left:     &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+		Right:    &customExpression{},
+	}
+
+	if expected, actual := "(1 + custom)", expression.String(); actual != expected {
+		t.Errorf("Unexpected string. Expected %q; got %q", expected, actual)
+	}
+}
+
+// Build a program of n statements, each 'let xi = f(a, (b + 2) * -c)[i];'
+func largeProgram(n int) *Program {
+	identifier := func(name string) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+
+	program := &Program{}
+	for i := 0; i < n; i++ {
+		literal := strconv.Itoa(i)
+		program.Statements = append(program.Statements, &LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  identifier("x" + literal),
+			Value: &IndexExpression{
+				Left: &CallExpression{
+					Function: identifier("f"),
+					Arguments: []Expression{
+						identifier("a"),
+						&InfixExpression{
+							Operator: "*",
+							Left:     &InfixExpression{Operator: "+", Left: identifier("b"), Right: &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2}},
+							Right:    &PrefixExpression{Operator: "-", Right: identifier("c")},
+						},
+					},
+				},
+				Index: &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal}, Value: int64(i)},
+			},
+		})
+	}
+
+	return program
+}
+
+func TestStringAllocations(t *testing.T) {
+	program := largeProgram(10000)
+
+	if expected, actual := "let x9999 = (f(a, ((b + 2) * (-c)))[9999]);", program.Statements[9999].String(); actual != expected {
+		t.Errorf("Unexpected string. Expected %q; got %q", expected, actual)
+	}
+
+	// The whole program is written into one builder, which allocates only as it grows
+	if allocations := testing.AllocsPerRun(5, func() { _ = program.String() }); allocations > 100 {
+		t.Errorf("Unexpected allocations. Expected at most 100; got %.0f", allocations)
+	}
+}
+
+func BenchmarkStringLargeProgram(b *testing.B) {
+	program := largeProgram(10000)
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_ = program.String()
+	}
+}
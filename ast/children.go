@@ -0,0 +1,141 @@
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A node's child along with the field holding it, e.g., "Left" or "Arguments[1]"
+type child struct {
+	name string
+	node Node
+}
+
+// List a node's children in source order, omitting optional children that are absent
+func children(node Node) []child {
+	var result []child
+
+	addExpression := func(name string, expression Expression) {
+		if expression != nil {
+			result = append(result, child{name, expression})
+		}
+	}
+	addStatements := func(name string, statements []Statement) {
+		for i, statement := range statements {
+			result = append(result, child{indexedName(name, i), statement})
+		}
+	}
+	addExpressions := func(name string, expressions []Expression) {
+		for i, expression := range expressions {
+			result = append(result, child{indexedName(name, i), expression})
+		}
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		addStatements("Statements", node.Statements)
+	case *LetStatement:
+		if node.Name != nil {
+			result = append(result, child{"Name", node.Name})
+		}
+		addExpression("Value", node.Value)
+	case *ImportStatement:
+		if node.Path != nil {
+			result = append(result, child{"Path", node.Path})
+		}
+	case *ReturnStatement:
+		addExpression("ReturnValue", node.ReturnValue)
+	case *ExpressionStatement:
+		addExpression("Expression", node.Expression)
+	case *BlockStatement:
+		addStatements("Statements", node.Statements)
+	case *ForStatement:
+		if node.Variable != nil {
+			result = append(result, child{"Variable", node.Variable})
+		}
+		addExpression("Iterable", node.Iterable)
+		if node.Body != nil {
+			result = append(result, child{"Body", node.Body})
+		}
+	case *TryStatement:
+		if node.Body != nil {
+			result = append(result, child{"Body", node.Body})
+		}
+		if node.Parameter != nil {
+			result = append(result, child{"Parameter", node.Parameter})
+		}
+		if node.Handler != nil {
+			result = append(result, child{"Handler", node.Handler})
+		}
+	case *PrefixExpression:
+		addExpression("Right", node.Right)
+	case *InfixExpression:
+		addExpression("Left", node.Left)
+		addExpression("Right", node.Right)
+	case *AssignExpression:
+		addExpression("Target", node.Target)
+		addExpression("Value", node.Value)
+	case *SwitchExpression:
+		addExpression("Subject", node.Subject)
+		for i, c := range node.Cases {
+			result = append(result, child{indexedName("Cases", i), c})
+		}
+	case *SwitchCase:
+		addExpression("Value", node.Value)
+		if node.Body != nil {
+			result = append(result, child{"Body", node.Body})
+		}
+	case *InterpolatedString:
+		addExpressions("Parts", node.Parts)
+	case *CallExpression:
+		addExpression("Function", node.Function)
+		addExpressions("Arguments", node.Arguments)
+	case *IndexExpression:
+		addExpression("Left", node.Left)
+		addExpression("Index", node.Index)
+	case *SliceExpression:
+		addExpression("Left", node.Left)
+		addExpression("Low", node.Low)
+		addExpression("High", node.High)
+	case *TernaryExpression:
+		addExpression("Condition", node.Condition)
+		addExpression("Consequence", node.Consequence)
+		addExpression("Alternative", node.Alternative)
+	}
+
+	return result
+}
+
+func indexedName(name string, i int) string {
+	return name + "[" + strconv.Itoa(i) + "]"
+}
+
+// Name a node's type without its package, e.g., "InfixExpression"
+func nodeType(node Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+// Describe the operator, name, or literal value that distinguishes a node from others of its type, if any
+func nodeDetail(node Node) string {
+	switch node := node.(type) {
+	case *LetStatement:
+		return node.TokenLiteral()
+	case *Identifier:
+		return node.Value
+	case *IntegerLiteral:
+		return strconv.FormatInt(node.Value, 10)
+	case *StringLiteral:
+		return strconv.Quote(node.Value)
+	case *PrefixExpression:
+		return node.Operator
+	case *InfixExpression:
+		return node.Operator
+	case *AssignExpression:
+		return node.Operator
+	case *SwitchCase:
+		return node.TokenLiteral()
+	}
+
+	return ""
+}
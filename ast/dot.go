@@ -0,0 +1,47 @@
+package ast
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Write the tree rooted at node as a GraphViz DOT graph, e.g., for rendering with 'dot -Tsvg'.
+// Each node is labelled with its type and any operator, name, or literal value it holds, and each
+// edge with the field of the parent that holds the child
+func WriteDot(w io.Writer, node Node) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintln(out, "digraph AST {")
+	fmt.Fprintln(out, "\tnode [shape=box, fontname=monospace];")
+
+	next := 0
+	var visit func(node Node) int
+	visit = func(node Node) int {
+		id := next
+		next += 1
+
+		fmt.Fprintf(out, "\tn%d [label=%s];\n", id, strconv.Quote(dotLabel(node)))
+
+		for _, c := range children(node) {
+			childID := visit(c.node)
+			fmt.Fprintf(out, "\tn%d -> n%d [label=%s];\n", id, childID, strconv.Quote(c.name))
+		}
+
+		return id
+	}
+	visit(node)
+
+	fmt.Fprintln(out, "}")
+
+	return out.Flush()
+}
+
+// Label a node with its type followed by the detail that distinguishes it from others of its type
+func dotLabel(node Node) string {
+	if detail := nodeDetail(node); detail != "" {
+		return nodeType(node) + "\n" + detail
+	}
+	return nodeType(node)
+}
@@ -0,0 +1,48 @@
+package ast
+
+import (
+	"bytes"
+	"rowanlovejoy/monkey/token"
+	"testing"
+)
+
+func TestWriteDot(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{
+				Token: token.Token{Type: token.MINUS, Literal: "-"},
+				Expression: &InfixExpression{
+					Token:    token.Token{Type: token.PLUS, Literal: "+"},
+					Left:     &PrefixExpression{Token: token.Token{Type: token.MINUS, Literal: "-"}, Operator: "-", Right: &Identifier{Value: "a"}},
+					Operator: "+",
+					Right:    &StringLiteral{Token: token.Token{Type: token.STRING, Literal: "b"}, Value: "b"},
+				},
+			},
+		},
+	}
+
+	expected := `digraph AST {
+	node [shape=box, fontname=monospace];
+	n0 [label="Program"];
+	n1 [label="ExpressionStatement"];
+	n2 [label="InfixExpression\n+"];
+	n3 [label="PrefixExpression\n-"];
+	n4 [label="Identifier\na"];
+	n3 -> n4 [label="Right"];
+	n2 -> n3 [label="Left"];
+	n5 [label="StringLiteral\n\"b\""];
+	n2 -> n5 [label="Right"];
+	n1 -> n2 [label="Expression"];
+	n0 -> n1 [label="Statements[0]"];
+}
+`
+
+	var out bytes.Buffer
+	if err := WriteDot(&out, program); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if actual := out.String(); actual != expected {
+		t.Errorf("Unexpected DOT output. Expected %q; got %q", expected, actual)
+	}
+}
@@ -0,0 +1,76 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Report whether two trees have the same structure: the same types of node, holding the same operators,
+// names, and literal values, with the same children. Tokens and source positions aren't compared, so a
+// tree built by hand in a test equals the parsed tree it describes
+func Equal(a, b Node) bool {
+	return Diff(a, b) == ""
+}
+
+// Describe the first difference between two trees found in a depth-first walk, naming the path to it from
+// the root, e.g., 'Program.Statements[0].Value.Left: expected IntegerLiteral 1; got Identifier x'. Returns
+// an empty string if the trees are equal
+func Diff(expected, actual Node) string {
+	return diff(nodeType(expected), expected, actual)
+}
+
+func diff(path string, expected, actual Node) string {
+	if isNil(expected) || isNil(actual) {
+		if isNil(expected) && isNil(actual) {
+			return ""
+		}
+		return fmt.Sprintf("%s: expected %s; got %s", path, describe(expected), describe(actual))
+	}
+
+	if nodeType(expected) != nodeType(actual) || nodeDetail(expected) != nodeDetail(actual) {
+		return fmt.Sprintf("%s: expected %s; got %s", path, describe(expected), describe(actual))
+	}
+
+	expectedChildren := children(expected)
+	actualChildren := children(actual)
+
+	for i := 0; i < len(expectedChildren) || i < len(actualChildren); i++ {
+		switch {
+		case i >= len(actualChildren):
+			return fmt.Sprintf("%s: missing %s", path, expectedChildren[i].name)
+		case i >= len(expectedChildren):
+			return fmt.Sprintf("%s: unexpected %s", path, actualChildren[i].name)
+		case expectedChildren[i].name != actualChildren[i].name:
+			return fmt.Sprintf("%s: expected %s; got %s", path, expectedChildren[i].name, actualChildren[i].name)
+		}
+
+		childPath := path + "." + expectedChildren[i].name
+		if difference := diff(childPath, expectedChildren[i].node, actualChildren[i].node); difference != "" {
+			return difference
+		}
+	}
+
+	return ""
+}
+
+// Report whether node is absent, either a nil interface or a nil pointer to a node
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Pointer && value.IsNil()
+}
+
+// Describe a node for a diff as its type and distinguishing detail, e.g., 'InfixExpression +'
+func describe(node Node) string {
+	if isNil(node) {
+		return "nil"
+	}
+
+	if detail := nodeDetail(node); detail != "" {
+		return nodeType(node) + " " + detail
+	}
+	return nodeType(node)
+}
@@ -0,0 +1,93 @@
+package ast
+
+import (
+	"rowanlovejoy/monkey/token"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	parsed := &LetStatement{
+		Token: token.Token{Type: token.LET, Literal: "let", Pos: token.Position{Line: 1, Column: 1}},
+		Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"},
+		Value: &InfixExpression{
+			Token:    token.Token{Type: token.PLUS, Literal: "+"},
+			Left:     &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+			Operator: "+",
+			Right:    &Identifier{Token: token.Token{Type: token.IDENT, Literal: "y"}, Value: "y"},
+		},
+	}
+
+	// Built without tokens or positions, as a test would
+	expected := &LetStatement{
+		Token: token.Token{Literal: "let"},
+		Name:  &Identifier{Value: "x"},
+		Value: &InfixExpression{
+			Left:     &IntegerLiteral{Value: 1},
+			Operator: "+",
+			Right:    &Identifier{Value: "y"},
+		},
+	}
+
+	if !Equal(expected, parsed) {
+		t.Errorf("Expected trees to be equal; got difference %q", Diff(expected, parsed))
+	}
+
+	if !Equal(nil, nil) {
+		t.Errorf("Expected nil trees to be equal")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tree := func(right Expression) *ExpressionStatement {
+		return &ExpressionStatement{
+			Expression: &InfixExpression{Left: &IntegerLiteral{Value: 1}, Operator: "+", Right: right},
+		}
+	}
+
+	tests := []struct {
+		expected Node
+		actual   Node
+		diff     string
+	}{
+		{
+			tree(&Identifier{Value: "x"}),
+			tree(&Identifier{Value: "x"}),
+			"",
+		},
+		{
+			tree(&Identifier{Value: "x"}),
+			tree(&Identifier{Value: "y"}),
+			"ExpressionStatement.Expression.Right: expected Identifier x; got Identifier y",
+		},
+		{
+			tree(&Identifier{Value: "x"}),
+			tree(&IntegerLiteral{Value: 2}),
+			"ExpressionStatement.Expression.Right: expected Identifier x; got IntegerLiteral 2",
+		},
+		{
+			tree(&Identifier{Value: "x"}),
+			tree(nil),
+			"ExpressionStatement.Expression: missing Right",
+		},
+		{
+			&CallExpression{Function: &Identifier{Value: "f"}},
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{&StringLiteral{Value: "a"}}},
+			"CallExpression: unexpected Arguments[0]",
+		},
+		{
+			&Program{},
+			nil,
+			"Program: expected Program; got nil",
+		},
+	}
+
+	for _, test := range tests {
+		if diff := Diff(test.expected, test.actual); diff != test.diff {
+			t.Errorf("Unexpected diff. Expected %q; got %q", test.diff, diff)
+		}
+
+		if equal := Equal(test.expected, test.actual); equal != (test.diff == "") {
+			t.Errorf("Unexpected equality. Expected %t; got %t", test.diff == "", equal)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package ast
+
+// Traverse the tree rooted at node depth-first in source order, calling fn for each node. If fn returns true,
+// the node's children are visited in turn, followed by a call of fn(nil) marking the end of the node. Absent
+// optional children are skipped
+func Inspect(node Node, fn func(Node) bool) {
+	if isNil(node) || !fn(node) {
+		return
+	}
+
+	for _, c := range children(node) {
+		Inspect(c.node, fn)
+	}
+
+	fn(nil)
+}
@@ -0,0 +1,55 @@
+package ast
+
+import (
+	"rowanlovejoy/monkey/token"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  &Identifier{Value: "x"},
+				Value: &InfixExpression{
+					Operator: "+",
+					Left:     &IntegerLiteral{Value: 1},
+					Right:    &PrefixExpression{Operator: "-", Right: &Identifier{Value: "y"}},
+				},
+			},
+			&ReturnStatement{},
+		},
+	}
+
+	var visited []string
+	Inspect(program, func(node Node) bool {
+		if node == nil {
+			visited = append(visited, "end")
+			return false
+		}
+
+		visited = append(visited, dotLabel(node))
+
+		// Skip the operand of prefix expressions
+		_, isPrefix := node.(*PrefixExpression)
+		return !isPrefix
+	})
+
+	expected := []string{
+		"Program",
+		"LetStatement\nlet",
+		"Identifier\nx", "end",
+		"InfixExpression\n+",
+		"IntegerLiteral\n1", "end",
+		"PrefixExpression\n-",
+		"end",
+		"end",
+		"ReturnStatement", "end",
+		"end",
+	}
+
+	if strings.Join(visited, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected nodes visited. Expected %q; got %q", expected, visited)
+	}
+}
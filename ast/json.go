@@ -0,0 +1,102 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"rowanlovejoy/monkey/token"
+	"strconv"
+	"strings"
+)
+
+// Write the tree rooted at node as indented JSON, e.g., for consumption by tools written in other languages.
+// Each node is an object holding its "type", its "pos" and "end" in the source, any keyword, operator, or
+// literal value that distinguishes it from others of its type, any "doc" comment text, and a property for each
+// child named after the field holding it, e.g., "left". Children held in lists are arrays. Absent optional children and empty lists
+// are omitted
+func WriteJSON(w io.Writer, node Node) error {
+	var compact bytes.Buffer
+	writeJSONNode(&compact, node)
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact.Bytes(), "", "\t"); err != nil {
+		return err
+	}
+	indented.WriteString("\n")
+
+	_, err := indented.WriteTo(w)
+	return err
+}
+
+func writeJSONNode(out *bytes.Buffer, node Node) {
+	fmt.Fprintf(out, `{"type":%s,"pos":%s,"end":%s`, jsonString(nodeType(node)), jsonPosition(node.Pos()), jsonPosition(node.End()))
+
+	switch node := node.(type) {
+	case *LetStatement:
+		fmt.Fprintf(out, `,"keyword":%s`, jsonString(node.TokenLiteral()))
+		if len(node.Doc) > 0 {
+			fmt.Fprintf(out, `,"doc":%s`, jsonString(node.DocText()))
+		}
+	case *SwitchCase:
+		fmt.Fprintf(out, `,"keyword":%s`, jsonString(node.TokenLiteral()))
+	case *Identifier:
+		fmt.Fprintf(out, `,"value":%s`, jsonString(node.Value))
+	case *IntegerLiteral:
+		fmt.Fprintf(out, `,"value":%s`, strconv.FormatInt(node.Value, 10))
+	case *StringLiteral:
+		fmt.Fprintf(out, `,"value":%s`, jsonString(node.Value))
+	case *PrefixExpression:
+		fmt.Fprintf(out, `,"operator":%s`, jsonString(node.Operator))
+	case *InfixExpression:
+		fmt.Fprintf(out, `,"operator":%s`, jsonString(node.Operator))
+	case *AssignExpression:
+		fmt.Fprintf(out, `,"operator":%s`, jsonString(node.Operator))
+	}
+
+	nodeChildren := children(node)
+
+	// Children of a list field are adjacent and named with their index, e.g., "Arguments[0]", "Arguments[1]"
+	for i := 0; i < len(nodeChildren); {
+		field, _, isList := strings.Cut(nodeChildren[i].name, "[")
+
+		fmt.Fprintf(out, `,%s:`, jsonString(jsonName(field)))
+
+		if !isList {
+			writeJSONNode(out, nodeChildren[i].node)
+			i += 1
+			continue
+		}
+
+		out.WriteString("[")
+		for first := i; i < len(nodeChildren) && strings.HasPrefix(nodeChildren[i].name, field+"["); i += 1 {
+			if i > first {
+				out.WriteString(",")
+			}
+			writeJSONNode(out, nodeChildren[i].node)
+		}
+		out.WriteString("]")
+	}
+
+	out.WriteString("}")
+}
+
+func jsonPosition(position token.Position) string {
+	return fmt.Sprintf(`{"line":%d,"column":%d,"offset":%d}`, position.Line, position.Column, position.Offset)
+}
+
+// Name the JSON property for a field, e.g., "returnValue" for ReturnValue
+func jsonName(field string) string {
+	return strings.ToLower(field[:1]) + field[1:]
+}
+
+// Encode s as a JSON string, leaving characters such as < and & unescaped as they're common in operators
+func jsonString(s string) string {
+	var out bytes.Buffer
+
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s) // Encoding a string can't fail
+
+	return strings.TrimSuffix(out.String(), "\n")
+}
@@ -0,0 +1,70 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"rowanlovejoy/monkey/token"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{
+				Token: token.Token{Type: token.IDENT, Literal: "f"},
+				Expression: &CallExpression{
+					Token:    token.Token{Type: token.LPAREN, Literal: "("},
+					Function: &Identifier{Token: token.Token{Type: token.IDENT, Literal: "f", Pos: token.Position{Line: 1, Column: 1, Offset: 0}, End: token.Position{Line: 1, Column: 2, Offset: 1}}, Value: "f"},
+					Arguments: []Expression{
+						&InfixExpression{
+							Token:    token.Token{Type: token.LTLT, Literal: "<<"},
+							Left:     &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1", Pos: token.Position{Line: 1, Column: 3, Offset: 2}, End: token.Position{Line: 1, Column: 4, Offset: 3}}, Value: 1},
+							Operator: "<<",
+							Right:    &StringLiteral{Token: token.Token{Type: token.STRING, Literal: "b", Pos: token.Position{Line: 1, Column: 8, Offset: 7}, End: token.Position{Line: 1, Column: 11, Offset: 10}}, Value: "b"},
+						},
+					},
+					RParen: token.Token{Type: token.RPAREN, Literal: ")", Pos: token.Position{Line: 1, Column: 11, Offset: 10}, End: token.Position{Line: 1, Column: 12, Offset: 11}},
+				},
+			},
+		},
+	}
+
+	expected := `{"type":"Program","pos":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":12,"offset":11},` +
+		`"statements":[{"type":"ExpressionStatement","pos":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":12,"offset":11},` +
+		`"expression":{"type":"CallExpression","pos":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":12,"offset":11},` +
+		`"function":{"type":"Identifier","pos":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":2,"offset":1},"value":"f"},` +
+		`"arguments":[{"type":"InfixExpression","pos":{"line":1,"column":3,"offset":2},"end":{"line":1,"column":11,"offset":10},"operator":"<<",` +
+		`"left":{"type":"IntegerLiteral","pos":{"line":1,"column":3,"offset":2},"end":{"line":1,"column":4,"offset":3},"value":1},` +
+		`"right":{"type":"StringLiteral","pos":{"line":1,"column":8,"offset":7},"end":{"line":1,"column":11,"offset":10},"value":"b"}}]}}]}`
+
+	var out bytes.Buffer
+	if err := WriteJSON(&out, program); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var actual bytes.Buffer
+	if err := json.Compact(&actual, out.Bytes()); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+
+	if actual.String() != expected {
+		t.Errorf("Unexpected JSON output. Expected %q; got %q", expected, actual.String())
+	}
+}
+
+func TestWriteJSONEmptyProgram(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJSON(&out, &Program{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var actual bytes.Buffer
+	if err := json.Compact(&actual, out.Bytes()); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+
+	expected := `{"type":"Program","pos":{"line":0,"column":0,"offset":0},"end":{"line":0,"column":0,"offset":0}}`
+	if actual.String() != expected {
+		t.Errorf("Unexpected JSON output. Expected %q; got %q", expected, actual.String())
+	}
+}
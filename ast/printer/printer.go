@@ -0,0 +1,400 @@
+package printer
+
+import (
+	"bytes"
+	"io"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/parser"
+	"rowanlovejoy/monkey/token"
+	"strings"
+)
+
+// Number of columns a tab occupies when measuring line width
+const TAB_WIDTH = 4
+
+// Controls how nodes are printed
+type Config struct {
+	Indent string // Unit of indentation written for each level of nesting, e.g., "\t" or "    "
+	Width  int    // Preferred maximum line width, kept to by breaking long argument lists. Zero or less disables wrapping
+	Debug  bool   // Print each node's fully parenthesised String() form instead of source
+}
+
+// Configuration used by Fprint and String: tab indentation and lines of up to 100 columns
+var DefaultConfig = Config{Indent: "\t", Width: 100}
+
+// Print node as Monkey source using DefaultConfig
+func Fprint(w io.Writer, node ast.Node) error {
+	return DefaultConfig.Fprint(w, node)
+}
+
+// Return node as Monkey source using DefaultConfig
+func String(node ast.Node) string {
+	return DefaultConfig.String(node)
+}
+
+// Print node as Monkey source: one statement per line, each terminated with a semicolon, single spaces
+// around infix operators, and only the parentheses required by precedence
+func (c Config) Fprint(w io.Writer, node ast.Node) error {
+	_, err := io.WriteString(w, c.String(node))
+	return err
+}
+
+// Return node as Monkey source, formatted as for Fprint
+func (c Config) String(node ast.Node) string {
+	if c.Debug {
+		return node.String()
+	}
+
+	p := &printer{config: c}
+	p.writeNode(node)
+	return p.out.String()
+}
+
+type printer struct {
+	config Config
+	out    bytes.Buffer
+	depth  int // Current nesting level, used to indent statements
+	column int // Width of the line written so far, used to decide where to break lines
+}
+
+// Write s, tracking the column at which the next write will begin
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.column = 0
+		s = s[i+1:]
+	}
+	p.column += width(s)
+}
+
+// Measure the columns s occupies, counting tabs as TAB_WIDTH
+func width(s string) int {
+	return len(s) + strings.Count(s, "\t")*(TAB_WIDTH-1)
+}
+
+func (p *printer) writeNode(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, statement := range node.Statements {
+			p.writeStatement(statement)
+		}
+	case *ast.BlockStatement:
+		p.writeBlock(node)
+	case *ast.SwitchCase:
+		p.writeSwitchCase(node)
+	case ast.Statement:
+		p.writeStatement(node)
+	case ast.Expression:
+		p.writeExpression(node, parser.LOWEST)
+	default:
+		p.write(node.String())
+	}
+}
+
+func (p *printer) writeIndent() {
+	for i := 0; i < p.depth; i++ {
+		p.write(p.config.Indent)
+	}
+}
+
+func (p *printer) writeStatement(statement ast.Statement) {
+	// Doc comment lines are kept as written, each on its own line at the statement's indentation
+	if let, ok := statement.(*ast.LetStatement); ok {
+		for _, line := range let.Doc {
+			p.writeIndent()
+			p.write(line.Literal + "\n")
+		}
+	}
+
+	p.writeIndent()
+
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		p.write(statement.TokenLiteral() + " ")
+		p.write(statement.Name.Value)
+		p.write(" = ")
+		p.writeExpression(statement.Value, parser.LOWEST)
+	case *ast.ImportStatement:
+		p.write("import ")
+		p.writeExpression(statement.Path, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.write("return")
+		if statement.ReturnValue != nil {
+			p.write(" ")
+			p.writeExpression(statement.ReturnValue, parser.LOWEST)
+		}
+	case *ast.BreakStatement:
+		p.write("break")
+	case *ast.ContinueStatement:
+		p.write("continue")
+	case *ast.ExpressionStatement:
+		p.writeExpression(statement.Expression, parser.LOWEST)
+		if _, ok := statement.Expression.(*ast.SwitchExpression); ok {
+			p.write("\n")
+			return
+		}
+	case *ast.ForStatement:
+		p.write("for (")
+		p.write(statement.Variable.Value)
+		p.write(" in ")
+		p.writeExpression(statement.Iterable, parser.LOWEST)
+		p.write(") ")
+		p.writeBlock(statement.Body)
+		// Statements ending in a block aren't terminated with a semicolon
+		p.write("\n")
+		return
+	case *ast.TryStatement:
+		p.write("try ")
+		p.writeBlock(statement.Body)
+		p.write(" catch (")
+		p.write(statement.Parameter.Value)
+		p.write(") ")
+		p.writeBlock(statement.Handler)
+		p.write("\n")
+		return
+	default:
+		// A statement the printer doesn't know, e.g., from an extension, is written as its String
+		p.write(strings.TrimSuffix(statement.String(), ";"))
+	}
+
+	p.write(";\n")
+}
+
+// Write a braced block with its statements indented one level deeper than the current line
+func (p *printer) writeBlock(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 {
+		p.write("{}")
+		return
+	}
+
+	p.write("{\n")
+
+	p.depth += 1
+	for _, statement := range block.Statements {
+		p.writeStatement(statement)
+	}
+	p.depth -= 1
+
+	p.writeIndent()
+	p.write("}")
+}
+
+// Write a case or default branch of a switch, starting at the current indentation
+func (p *printer) writeSwitchCase(switchCase *ast.SwitchCase) {
+	p.writeIndent()
+
+	if switchCase.IsDefault() {
+		p.write("default:\n")
+	} else {
+		p.write("case ")
+		p.writeExpression(switchCase.Value, parser.LOWEST)
+		p.write(":\n")
+	}
+
+	p.depth += 1
+	for _, statement := range switchCase.Body.Statements {
+		p.writeStatement(statement)
+	}
+	p.depth -= 1
+}
+
+// Write a call's arguments on one line if they fit within the configured width, otherwise one per line, each
+// followed by a comma
+func (p *printer) writeArguments(arguments []ast.Expression) {
+	if len(arguments) == 0 {
+		p.write("()")
+		return
+	}
+
+	if p.config.Width <= 0 || p.column+width(p.singleLineArguments(arguments)) <= p.config.Width {
+		p.write("(")
+		for i, argument := range arguments {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.writeExpression(argument, parser.LOWEST)
+		}
+		p.write(")")
+		return
+	}
+
+	p.write("(\n")
+
+	p.depth += 1
+	for _, argument := range arguments {
+		p.writeIndent()
+		p.writeExpression(argument, parser.LOWEST)
+		p.write(",\n")
+	}
+	p.depth -= 1
+
+	p.writeIndent()
+	p.write(")")
+}
+
+// Render arguments as they'd appear on one line, up to the first line break of any multi-line argument
+func (p *printer) singleLineArguments(arguments []ast.Expression) string {
+	// Measured without a width so that nested argument lists don't break while measuring
+	measure := &printer{config: Config{Indent: p.config.Indent}, depth: p.depth}
+
+	measure.write("(")
+	for i, argument := range arguments {
+		if i > 0 {
+			measure.write(", ")
+		}
+		measure.writeExpression(argument, parser.LOWEST)
+	}
+	measure.write(")")
+
+	line, _, _ := strings.Cut(measure.out.String(), "\n")
+	return line
+}
+
+// Write an expression appearing in a context that binds at least as tightly as minPrecedence,
+// parenthesising it if its own operator would otherwise bind more loosely
+func (p *printer) writeExpression(expression ast.Expression, minPrecedence int) {
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		p.write(expression.Value)
+	case *ast.IntegerLiteral:
+		// A negative value, produced by folding, is parenthesised where its prefix expression would be, and under
+		// another prefix, e.g., '-(-9223372036854775808)', where the unparenthesised literal wouldn't parse
+		if expression.Value < 0 && parser.PREFIX <= minPrecedence {
+			p.write("(" + expression.TokenLiteral() + ")")
+		} else {
+			p.write(expression.TokenLiteral())
+		}
+	case *ast.NullLiteral:
+		p.write("null")
+	case *ast.StringLiteral:
+		p.write(`"` + ast.EscapeString(expression.Value) + `"`)
+	case *ast.InterpolatedString:
+		p.write(`"`)
+		for _, part := range expression.Parts {
+			if text, ok := part.(*ast.StringLiteral); ok {
+				p.write(ast.EscapeString(text.Value))
+			} else {
+				p.write("${")
+				p.writeExpression(part, parser.LOWEST)
+				p.write("}")
+			}
+		}
+		p.write(`"`)
+	case *ast.CallExpression:
+		p.writeExpression(expression.Function, parser.CALL)
+		p.writeArguments(expression.Arguments)
+	case *ast.IndexExpression:
+		p.writeExpression(expression.Left, parser.INDEX)
+
+		if key, ok := expression.Index.(*ast.StringLiteral); ok && expression.Token.Type == token.DOT {
+			p.write("." + key.Value)
+		} else {
+			p.write("[")
+			p.writeExpression(expression.Index, parser.LOWEST)
+			p.write("]")
+		}
+	case *ast.SliceExpression:
+		p.writeExpression(expression.Left, parser.INDEX)
+		p.write("[")
+		if expression.Low != nil {
+			p.writeExpression(expression.Low, parser.LOWEST)
+		}
+		p.write(":")
+		if expression.High != nil {
+			p.writeExpression(expression.High, parser.LOWEST)
+		}
+		p.write("]")
+	case *ast.PrefixExpression:
+		needsParens := parser.PREFIX < minPrecedence
+
+		if needsParens {
+			p.write("(")
+		}
+
+		p.write(expression.Operator)
+		p.writeExpression(expression.Right, parser.PREFIX)
+
+		if needsParens {
+			p.write(")")
+		}
+	case *ast.InfixExpression:
+		precedence := parser.Precedence(expression.Token.Type)
+		needsParens := precedence < minPrecedence
+
+		if needsParens {
+			p.write("(")
+		}
+
+		// Infix operators are left-associative, so a right operand of equal precedence needs parentheses. So does
+		// a comparison compared in turn, which wouldn't parse as a chain
+		leftPrecedence := precedence
+		if left, ok := expression.Left.(*ast.InfixExpression); ok && parser.IsComparison(left.Token.Type) && parser.IsComparison(expression.Token.Type) {
+			leftPrecedence += 1
+		}
+		p.writeExpression(expression.Left, leftPrecedence)
+		p.write(" " + expression.Operator + " ")
+		p.writeExpression(expression.Right, precedence+1)
+
+		if needsParens {
+			p.write(")")
+		}
+	case *ast.AssignExpression:
+		needsParens := parser.ASSIGN < minPrecedence
+
+		if needsParens {
+			p.write("(")
+		}
+
+		// Assignment is right-associative, so a nested assignment on the right needs no parentheses
+		p.writeExpression(expression.Target, parser.ASSIGN+1)
+		p.write(" " + expression.Operator + " ")
+		p.writeExpression(expression.Value, parser.ASSIGN)
+
+		if needsParens {
+			p.write(")")
+		}
+	case *ast.SwitchExpression:
+		p.write("switch (")
+		p.writeExpression(expression.Subject, parser.LOWEST)
+		p.write(") ")
+
+		if len(expression.Cases) == 0 {
+			p.write("{}")
+			return
+		}
+
+		p.write("{\n")
+
+		p.depth += 1
+		for _, switchCase := range expression.Cases {
+			p.writeSwitchCase(switchCase)
+		}
+		p.depth -= 1
+
+		p.writeIndent()
+		p.write("}")
+	case *ast.TernaryExpression:
+		needsParens := parser.TERNARY < minPrecedence
+
+		if needsParens {
+			p.write("(")
+		}
+
+		// Ternaries are right-associative, so only a nested ternary in the condition needs parentheses
+		p.writeExpression(expression.Condition, parser.TERNARY+1)
+		p.write(" ? ")
+		p.writeExpression(expression.Consequence, parser.LOWEST)
+		p.write(" : ")
+		p.writeExpression(expression.Alternative, parser.TERNARY)
+
+		if needsParens {
+			p.write(")")
+		}
+	default:
+		// An expression the printer doesn't know is written as its String, which is fully parenthesised
+		if expression != nil {
+			p.write(expression.String())
+		}
+	}
+}
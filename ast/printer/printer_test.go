@@ -0,0 +1,196 @@
+package printer
+
+import (
+	"bytes"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/optimizer"
+	"rowanlovejoy/monkey/parser"
+	"testing"
+)
+
+func TestConfigString(t *testing.T) {
+	tests := []struct {
+		config   Config
+		input    string
+		expected string
+	}{
+		{
+			DefaultConfig,
+			"for (x in xs) { let y = x*2; }",
+			"for (x in xs) {\n\tlet y = x * 2;\n}\n",
+		},
+		{
+			DefaultConfig,
+			"for (x in xs) { continue\nbreak }",
+			"for (x in xs) {\n\tcontinue;\n\tbreak;\n}\n",
+		},
+		{
+			DefaultConfig,
+			"/// Total\n///   so far\nlet total = 0;\nfor (x in xs) {\n/// Doubled\nlet y = x*2; }",
+			"/// Total\n///   so far\nlet total = 0;\nfor (x in xs) {\n\t/// Doubled\n\tlet y = x * 2;\n}\n",
+		},
+		{
+			DefaultConfig,
+			`a[1:n-1][ : 2]; a["b"]; a["c d"]; a.e[f];`,
+			"a[1:n - 1][:2];\na[\"b\"];\na[\"c d\"];\na.e[f];\n",
+		},
+		{
+			DefaultConfig,
+			`"say \"hi\" \${x} $5 C:\dir\\"; "${a}\\${b}$"`,
+			`"say \"hi\" \${x} $5 C:\dir\\";` + "\n" + `"${a}\\${b}$";` + "\n",
+		},
+		{
+			DefaultConfig,
+			"a[i]+=1; x.y*=2",
+			"a[i] += 1;\nx.y *= 2;\n",
+		},
+		{
+			Config{Indent: "  "},
+			"for (x in xs) { let y = x*2; }",
+			"for (x in xs) {\n  let y = x * 2;\n}\n",
+		},
+		{
+			Config{Indent: "\t", Width: 20},
+			"let total = add(first, second, third);",
+			"let total = add(\n\tfirst,\n\tsecond,\n\tthird,\n);\n",
+		},
+		{
+			Config{Indent: "\t", Width: 40},
+			"let total = add(first, second, third);",
+			"let total = add(first, second, third);\n",
+		},
+		{
+			Config{Indent: "\t"},
+			"let total = add(first, second, third, fourth, fifth, sixth, seventh, eighth, ninth, tenth);",
+			"let total = add(first, second, third, fourth, fifth, sixth, seventh, eighth, ninth, tenth);\n",
+		},
+		{
+			Config{Indent: "\t", Width: 24},
+			"for (x in xs) { f(first, second); }",
+			"for (x in xs) {\n\tf(first, second);\n}\n",
+		},
+		{
+			// Tabs count as TAB_WIDTH columns, so the indented call no longer fits
+			Config{Indent: "\t", Width: 19},
+			"for (x in xs) { f(first, second); }",
+			"for (x in xs) {\n\tf(\n\t\tfirst,\n\t\tsecond,\n\t);\n}\n",
+		},
+		{
+			Config{Indent: "\t", Width: 21},
+			"f(a, g(first, second))",
+			"f(\n\ta,\n\tg(first, second),\n);\n",
+		},
+		{
+			// A trailing comma isn't kept when the arguments fit on one line
+			Config{Indent: "\t", Width: 40},
+			"f(\n\tfirst,\n\tsecond,\n)",
+			"f(first, second);\n",
+		},
+		{
+			// A comparison compared in turn keeps its parentheses, without which it would be a chain
+			DefaultConfig,
+			"(a < b) < c; a < (b < c); (a < b) == (c > d);",
+			"(a < b) < c;\na < (b < c);\na < b == c > d;\n",
+		},
+		{
+			Config{Debug: true},
+			"let x = a + b * c;",
+			"let x = (a + (b * c));",
+		},
+	}
+
+	for _, test := range tests {
+		p := parser.New(lexer.New(test.input))
+		program := p.ParseProgram()
+		if errors := p.Errors(); len(errors) > 0 {
+			t.Fatalf("Parser errors for %q: %v", test.input, errors)
+		}
+
+		actual := test.config.String(program)
+		if actual != test.expected {
+			t.Errorf("Unexpected output for %q. Expected %q; got %q", test.input, test.expected, actual)
+		}
+
+		// The output parses back to the same program, trailing commas included
+		reparser := parser.New(lexer.New(actual))
+		if reparsed := reparser.ParseProgram(); len(reparser.Errors()) > 0 || !ast.Equal(program, reparsed) {
+			t.Errorf("Output for %q doesn't parse back to the same program: %q, errors %v", test.input, actual, reparser.Errors())
+		}
+	}
+}
+
+func TestPrintFoldedNegativeLiteral(t *testing.T) {
+	p := parser.New(lexer.New("-(-9223372036854775807 - 1); (0 - 5).x; x - (0 - 5);"))
+	program := optimizer.Fold(p.ParseProgram())
+	if errors := p.Errors(); len(errors) > 0 {
+		t.Fatalf("Parser errors: %v", errors)
+	}
+
+	expected := "-(-9223372036854775808);\n(-5).x;\nx - -5;\n"
+	if actual := DefaultConfig.String(program); actual != expected {
+		t.Errorf("Unexpected output. Expected %q; got %q", expected, actual)
+	}
+}
+
+func TestPrintExpression(t *testing.T) {
+	expression, errors := parser.ParseExpressionString("-(a + b) * c")
+	if len(errors) > 0 {
+		t.Fatalf("Parser errors: %v", errors)
+	}
+
+	var out bytes.Buffer
+	if err := Fprint(&out, expression); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if expected := "-(a + b) * c"; out.String() != expected {
+		t.Errorf("Unexpected output. Expected %q; got %q", expected, out.String())
+	}
+}
+
+func TestPrintSwitchCase(t *testing.T) {
+	expression, errors := parser.ParseExpressionString("switch (x) { case 1: a; b }")
+	if len(errors) > 0 {
+		t.Fatalf("Parser errors: %v", errors)
+	}
+
+	switchCase := expression.(*ast.SwitchExpression).Cases[0]
+
+	if expected, actual := "case 1:\n\ta;\n\tb;\n", String(switchCase); actual != expected {
+		t.Errorf("Unexpected output. Expected %q; got %q", expected, actual)
+	}
+}
+
+// An expression node the printer has no case for, as an extension might add
+type customExpression struct {
+	ast.Identifier
+}
+
+func (ce *customExpression) String() string {
+	return "custom(" + ce.Value + ")"
+}
+
+// A statement node the printer has no case for
+type customStatement struct {
+	ast.ExpressionStatement
+}
+
+func (cs *customStatement) String() string {
+	return "custom;"
+}
+
+func TestPrintUnknownNodes(t *testing.T) {
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.ExpressionStatement{Expression: &ast.InfixExpression{
+			Left:     &customExpression{ast.Identifier{Value: "a"}},
+			Operator: "*",
+			Right:    &ast.Identifier{Value: "b"},
+		}},
+		&customStatement{},
+	}}
+
+	if expected, actual := "custom(a) * b;\ncustom;\n", String(program); actual != expected {
+		t.Errorf("Unexpected output. Expected %q; got %q", expected, actual)
+	}
+}
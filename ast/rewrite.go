@@ -0,0 +1,103 @@
+package ast
+
+// Function applied to each node by Rewrite, returning the node to take its place
+type RewriteFunc func(Node) Node
+
+// Rewrite the tree rooted at node in place, bottom-up: each node's children are rewritten first, then fn is applied
+// to the node itself and its result replaces the node in its parent, so fn can simply return its argument to leave
+// a node unchanged. A replacement that doesn't fit where the node was, e.g., a non-identifier for a let statement's
+// name, is ignored, keeping the original node. A nil replacement removes an element of a list, e.g., a statement
+// of a block, and is otherwise ignored too. Absent children, including typed nils such as the name of a let
+// statement that failed to parse, aren't passed to fn
+func Rewrite(node Node, fn RewriteFunc) Node {
+	if isNil(node) {
+		return node
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		node.Statements = rewriteList(node.Statements, fn)
+	case *LetStatement:
+		node.Name = rewriteChild(node.Name, fn)
+		node.Value = rewriteChild(node.Value, fn)
+	case *ImportStatement:
+		node.Path = rewriteChild(node.Path, fn)
+	case *ReturnStatement:
+		node.ReturnValue = rewriteChild(node.ReturnValue, fn)
+	case *ExpressionStatement:
+		node.Expression = rewriteChild(node.Expression, fn)
+	case *BlockStatement:
+		node.Statements = rewriteList(node.Statements, fn)
+	case *ForStatement:
+		node.Variable = rewriteChild(node.Variable, fn)
+		node.Iterable = rewriteChild(node.Iterable, fn)
+		node.Body = rewriteChild(node.Body, fn)
+	case *TryStatement:
+		node.Body = rewriteChild(node.Body, fn)
+		node.Parameter = rewriteChild(node.Parameter, fn)
+		node.Handler = rewriteChild(node.Handler, fn)
+	case *PrefixExpression:
+		node.Right = rewriteChild(node.Right, fn)
+	case *InfixExpression:
+		node.Left = rewriteChild(node.Left, fn)
+		node.Right = rewriteChild(node.Right, fn)
+	case *AssignExpression:
+		node.Target = rewriteChild(node.Target, fn)
+		node.Value = rewriteChild(node.Value, fn)
+	case *SwitchExpression:
+		node.Subject = rewriteChild(node.Subject, fn)
+		node.Cases = rewriteList(node.Cases, fn)
+	case *SwitchCase:
+		node.Value = rewriteChild(node.Value, fn)
+		node.Body = rewriteChild(node.Body, fn)
+	case *InterpolatedString:
+		node.Parts = rewriteList(node.Parts, fn)
+	case *CallExpression:
+		node.Function = rewriteChild(node.Function, fn)
+		node.Arguments = rewriteList(node.Arguments, fn)
+	case *IndexExpression:
+		node.Left = rewriteChild(node.Left, fn)
+		node.Index = rewriteChild(node.Index, fn)
+	case *SliceExpression:
+		node.Left = rewriteChild(node.Left, fn)
+		node.Low = rewriteChild(node.Low, fn)
+		node.High = rewriteChild(node.High, fn)
+	case *TernaryExpression:
+		node.Condition = rewriteChild(node.Condition, fn)
+		node.Consequence = rewriteChild(node.Consequence, fn)
+		node.Alternative = rewriteChild(node.Alternative, fn)
+	}
+
+	return fn(node)
+}
+
+// Rewrite a child, skipping it if absent and keeping it if its replacement is nil or doesn't fit
+func rewriteChild[T Node](child T, fn RewriteFunc) T {
+	if isNil(child) {
+		return child
+	}
+
+	if replacement, ok := Rewrite(child, fn).(T); ok && !isNil(replacement) {
+		return replacement
+	}
+	return child
+}
+
+// Rewrite the elements of a list child in place, leaving out any whose replacement is nil and keeping any whose
+// replacement doesn't fit the list
+func rewriteList[T Node](nodes []T, fn RewriteFunc) []T {
+	rewritten := nodes[:0]
+	for _, node := range nodes {
+		replacement := Rewrite(node, fn)
+		if isNil(replacement) {
+			continue
+		}
+
+		if fitting, ok := replacement.(T); ok {
+			rewritten = append(rewritten, fitting)
+		} else {
+			rewritten = append(rewritten, node)
+		}
+	}
+	return rewritten
+}
@@ -0,0 +1,174 @@
+package ast
+
+import (
+	"fmt"
+	"rowanlovejoy/monkey/token"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	one := func() Expression {
+		return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1}
+	}
+	two := func() Expression {
+		return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2}
+	}
+	name := func() *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"}
+	}
+
+	// Replaces every integer literal 1 with 2
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	tests := []struct {
+		input    Node
+		expected string
+	}{
+		{
+			one(),
+			"2",
+		},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			"2",
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			"(2 + 2)",
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			"(2 + 2)",
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			"(-2)",
+		},
+		{
+			&LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: name(), Value: one()},
+			"let x = 2;",
+		},
+		{
+			&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}, ReturnValue: one()},
+			"return 2;",
+		},
+		{
+			&ForStatement{
+				Token:    token.Token{Type: token.FOR, Literal: "for"},
+				Variable: name(),
+				Iterable: one(),
+				Body:     &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			"for (x in 2) {2}",
+		},
+	}
+
+	for _, test := range tests {
+		if actual := Rewrite(test.input, turnOneIntoTwo).String(); actual != test.expected {
+			t.Errorf("Unexpected rewritten node. Expected %q; got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestRewriteVisitsBottomUp(t *testing.T) {
+	expression := &InfixExpression{
+		Left:     &PrefixExpression{Operator: "-", Right: &Identifier{Value: "a"}},
+		Operator: "*",
+		Right:    &Identifier{Value: "b"},
+	}
+
+	visited := []string{}
+	Rewrite(expression, func(node Node) Node {
+		visited = append(visited, node.String())
+		return node
+	})
+
+	expected := []string{"a", "(-a)", "b", "((-a) * b)"}
+	if len(visited) != len(expected) {
+		t.Fatalf("Unexpected visit count. Expected %d; got %d: %q", len(expected), len(visited), visited)
+	}
+	for i, s := range expected {
+		if visited[i] != s {
+			t.Errorf("Unexpected visit order at %d. Expected %q; got %q", i, s, visited[i])
+		}
+	}
+}
+
+func TestRewriteRemovesNilStatements(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &Identifier{Value: "a"}},
+		&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}},
+		&BlockStatement{Statements: []Statement{
+			&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}},
+			&ExpressionStatement{Expression: &Identifier{Value: "b"}},
+		}},
+	}}
+
+	// Removes every return statement, and replaces identifier b with a non-expression, which keeps b
+	Rewrite(program, func(node Node) Node {
+		switch node := node.(type) {
+		case *ReturnStatement:
+			return nil
+		case *Identifier:
+			if node.Value == "b" {
+				return &BlockStatement{}
+			}
+		}
+		return node
+	})
+
+	if expected, actual := "a{b}", program.String(); actual != expected {
+		t.Errorf("Unexpected rewritten program. Expected %q; got %q", expected, actual)
+	}
+}
+
+func TestRewriteKeepsChildrenWhoseReplacementDoesntFit(t *testing.T) {
+	name := &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"}
+	body := &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: &Identifier{Value: "y"}}}}
+	statement := &ForStatement{
+		Token:    token.Token{Type: token.FOR, Literal: "for"},
+		Variable: name,
+		Iterable: &Identifier{Value: "xs"},
+		Body:     body,
+	}
+
+	// Replaces the loop variable with a non-identifier and the body with nil, neither of which fits
+	Rewrite(statement, func(node Node) Node {
+		switch node {
+		case name:
+			return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1}
+		case body:
+			return nil
+		}
+		return node
+	})
+
+	if statement.Variable != name {
+		t.Errorf("Unexpected loop variable. Expected the original %v; got %v", name, statement.Variable)
+	}
+	if statement.Body != body {
+		t.Errorf("Unexpected loop body. Expected the original %v; got %v", body, statement.Body)
+	}
+}
+
+func TestRewriteSkipsNilChildren(t *testing.T) {
+	// E.g., a let statement whose name failed to parse
+	statement := &LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Value: &Identifier{Value: "a"}}
+
+	visited := []string{}
+	Rewrite(statement, func(node Node) Node {
+		visited = append(visited, fmt.Sprintf("%T", node))
+		return node
+	})
+
+	expected := []string{"*ast.Identifier", "*ast.LetStatement"}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected nodes visited. Expected %v; got %v", expected, visited)
+	}
+}
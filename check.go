@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"rowanlovejoy/monkey/analysis"
+	"rowanlovejoy/monkey/diagnostic"
+	"rowanlovejoy/monkey/module"
+	"rowanlovejoy/monkey/parser"
+	"slices"
+	"strings"
+)
+
+// Number of errors reported per file before check stops, unless set with --max-errors
+const DEFAULT_MAX_ERRORS = 10
+
+// Load each file named in args along with its imports without running it, printing every parse error found as
+// file:line:column: message followed by the offending line of source. Files that load are then analysed, with
+// the problems found printed likewise, while imports that fail are reported to errOut. Lints named in --disable,
+// separated by commas, aren't reported. Returns the process exit code: 0 if every file was free of errors, 1
+// otherwise; warnings don't affect it
+func checkFiles(args []string, out io.Writer, errOut io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	maxErrors := flags.Int("max-errors", DEFAULT_MAX_ERRORS, "stop after n errors in a file; 0 reports every error")
+	disable := flags.String("disable", "", "comma-separated lints not to report: "+strings.Join(analysis.Lints, ", "))
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(errOut, "usage: monkey check [--max-errors n] [--disable lints] file...")
+		return 2
+	}
+
+	var disabled []string
+	if *disable != "" {
+		disabled = strings.Split(*disable, ",")
+	}
+
+	for _, lint := range disabled {
+		if !slices.Contains(analysis.Lints, lint) {
+			fmt.Fprintf(errOut, "Unknown lint %s. Expected one of %s\n", lint, strings.Join(analysis.Lints, ", "))
+			return 2
+		}
+	}
+
+	loader := module.NewLoader(parser.WithMaxErrors(*maxErrors))
+	exitCode := 0
+
+	for _, path := range flags.Args() {
+		m, err := loader.Load(path)
+		if err != nil {
+			printLoadError(out, errOut, err)
+			exitCode = 1
+			continue
+		}
+
+		for _, d := range analysis.Check(m.Program, analysis.WithPredeclared(m.Imported()...), analysis.WithDisabled(disabled...)) {
+			fmt.Fprint(out, diagnostic.Format(path, m.Source, d))
+			if d.Severity == diagnostic.ERROR {
+				exitCode = 1
+			}
+		}
+	}
+
+	return exitCode
+}
+
+// Print an error from loading a module. Parse errors, in the file itself or one it imports, are printed to out with
+// the other problems found, while failed imports and files that can't be read are printed to errOut
+func printLoadError(out io.Writer, errOut io.Writer, err error) {
+	var parseErrors *module.ParseErrors
+	var importError *module.ImportError
+
+	switch {
+	case errors.As(err, &parseErrors):
+		printParseErrors(out, parseErrors.Path, parseErrors.Source, parseErrors.Errors)
+	case errors.As(err, &importError):
+		statement := importError.Statement
+		d := diagnostic.Diagnostic{Pos: statement.Pos(), End: statement.End(), Message: importError.Message}
+		fmt.Fprint(errOut, diagnostic.Format(importError.Module.Path, importError.Module.Source, d))
+	default:
+		fmt.Fprintf(errOut, "%s\n", err)
+	}
+}
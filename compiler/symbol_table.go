@@ -0,0 +1,112 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GLOBAL_SCOPE   SymbolScope = "GLOBAL"   // Bound at the top level of the program
+	LOCAL_SCOPE    SymbolScope = "LOCAL"    // Bound inside the function currently being compiled
+	BUILTIN_SCOPE  SymbolScope = "BUILTIN"  // Provided by the interpreter rather than the program
+	FREE_SCOPE     SymbolScope = "FREE"     // Bound in an enclosing function and captured by a closure
+	FUNCTION_SCOPE SymbolScope = "FUNCTION" // The name a function is bound to, referenced from its own body
+)
+
+// A name bound in the program, along with where its value will be found at runtime
+type Symbol struct {
+	Name     string
+	Scope    SymbolScope
+	Index    int  // Slot of the value within its scope's storage
+	Constant bool // Whether the binding was declared with const and must not be reassigned
+}
+
+// Maps names to symbols for one scope, falling back to its enclosing scope when resolving
+type SymbolTable struct {
+	Outer *SymbolTable // Enclosing scope; nil for the global scope
+
+	store          map[string]Symbol
+	numDefinitions int // Number of globals or locals defined, used to assign indices
+
+	FreeSymbols []Symbol // Symbols from enclosing scopes captured by this scope, in capture order
+}
+
+// Create the global symbol table
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store:       make(map[string]Symbol),
+		FreeSymbols: []Symbol{},
+	}
+}
+
+// Create a symbol table for a scope nested inside outer, e.g., a function body
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Bind name in this scope, assigning it the next free index
+func (s *SymbolTable) Define(name string) Symbol {
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GLOBAL_SCOPE
+	} else {
+		symbol.Scope = LOCAL_SCOPE
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions += 1
+	return symbol
+}
+
+// Bind name in this scope as a constant, which the compiler must refuse to reassign
+func (s *SymbolTable) DefineConstant(name string) Symbol {
+	symbol := s.Define(name)
+	symbol.Constant = true
+	s.store[name] = symbol
+	return symbol
+}
+
+// Bind name as the builtin at index. Builtins don't consume definition indices
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Scope: BUILTIN_SCOPE, Index: index}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Bind name as the function whose body this scope belongs to, allowing it to refer to itself
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Scope: FUNCTION_SCOPE, Index: 0}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Look up name in this scope and then each enclosing scope. Locals of an enclosing function are
+// captured as free symbols of this scope; globals and builtins are returned unchanged
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	if symbol, ok := s.store[name]; ok {
+		return symbol, true
+	}
+
+	if s.Outer == nil {
+		return Symbol{}, false
+	}
+
+	symbol, ok := s.Outer.Resolve(name)
+	if !ok {
+		return symbol, false
+	}
+
+	if symbol.Scope == GLOBAL_SCOPE || symbol.Scope == BUILTIN_SCOPE {
+		return symbol, true
+	}
+
+	return s.defineFree(symbol), true
+}
+
+// Record original as captured from an enclosing scope and bind its name as a free symbol here
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FREE_SCOPE, Index: len(s.FreeSymbols) - 1, Constant: original.Constant}
+	s.store[original.Name] = symbol
+	return symbol
+}
@@ -0,0 +1,174 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GLOBAL_SCOPE, Index: 0},
+		"b": {Name: "b", Scope: GLOBAL_SCOPE, Index: 1},
+		"c": {Name: "c", Scope: LOCAL_SCOPE, Index: 0},
+		"d": {Name: "d", Scope: LOCAL_SCOPE, Index: 1},
+		"e": {Name: "e", Scope: LOCAL_SCOPE, Index: 0},
+		"f": {Name: "f", Scope: LOCAL_SCOPE, Index: 1},
+	}
+
+	global := NewSymbolTable()
+	firstLocal := NewEnclosedSymbolTable(global)
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+
+	definitions := []struct {
+		table *SymbolTable
+		name  string
+	}{
+		{global, "a"},
+		{global, "b"},
+		{firstLocal, "c"},
+		{firstLocal, "d"},
+		{secondLocal, "e"},
+		{secondLocal, "f"},
+	}
+
+	for _, definition := range definitions {
+		if symbol := definition.table.Define(definition.name); symbol != expected[definition.name] {
+			t.Errorf("Unexpected symbol for %s. Expected %+v; got %+v", definition.name, expected[definition.name], symbol)
+		}
+	}
+}
+
+func TestResolveNested(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+
+	tests := []struct {
+		table               *SymbolTable
+		expectedSymbols     []Symbol
+		expectedFreeSymbols []Symbol
+	}{
+		{
+			firstLocal,
+			[]Symbol{
+				{Name: "a", Scope: GLOBAL_SCOPE, Index: 0},
+				{Name: "len", Scope: BUILTIN_SCOPE, Index: 0},
+				{Name: "b", Scope: LOCAL_SCOPE, Index: 0},
+			},
+			[]Symbol{},
+		},
+		{
+			secondLocal,
+			[]Symbol{
+				{Name: "a", Scope: GLOBAL_SCOPE, Index: 0},
+				{Name: "len", Scope: BUILTIN_SCOPE, Index: 0},
+				{Name: "b", Scope: FREE_SCOPE, Index: 0},
+				{Name: "c", Scope: LOCAL_SCOPE, Index: 0},
+			},
+			[]Symbol{
+				{Name: "b", Scope: LOCAL_SCOPE, Index: 0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		for _, expected := range test.expectedSymbols {
+			symbol, ok := test.table.Resolve(expected.Name)
+			if !ok {
+				t.Errorf("Name %s not resolvable", expected.Name)
+				continue
+			}
+			if symbol != expected {
+				t.Errorf("Unexpected symbol for %s. Expected %+v; got %+v", expected.Name, expected, symbol)
+			}
+		}
+
+		if len(test.table.FreeSymbols) != len(test.expectedFreeSymbols) {
+			t.Errorf("Unexpected free symbol count. Expected %d; got %d", len(test.expectedFreeSymbols), len(test.table.FreeSymbols))
+			continue
+		}
+
+		for i, expected := range test.expectedFreeSymbols {
+			if symbol := test.table.FreeSymbols[i]; symbol != expected {
+				t.Errorf("Unexpected free symbol. Expected %+v; got %+v", expected, symbol)
+			}
+		}
+	}
+}
+
+func TestResolveUnresolvable(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("b")
+
+	for _, name := range []string{"c", "d"} {
+		if _, ok := local.Resolve(name); ok {
+			t.Errorf("Name %s resolved, but was never defined", name)
+		}
+	}
+}
+
+func TestDefineAndResolveFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineFunctionName("a")
+
+	expected := Symbol{Name: "a", Scope: FUNCTION_SCOPE, Index: 0}
+
+	symbol, ok := global.Resolve("a")
+	if !ok {
+		t.Fatalf("Function name %s not resolvable", expected.Name)
+	}
+
+	if symbol != expected {
+		t.Errorf("Unexpected symbol. Expected %+v; got %+v", expected, symbol)
+	}
+}
+
+func TestShadowingFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineFunctionName("a")
+	global.Define("a")
+
+	expected := Symbol{Name: "a", Scope: GLOBAL_SCOPE, Index: 0}
+
+	if symbol, _ := global.Resolve("a"); symbol != expected {
+		t.Errorf("Unexpected symbol. Expected %+v; got %+v", expected, symbol)
+	}
+}
+
+func TestDefineConstant(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineConstant("b")
+
+	local := NewEnclosedSymbolTable(global)
+	local.DefineConstant("c")
+
+	nested := NewEnclosedSymbolTable(local)
+
+	tests := []struct {
+		table    *SymbolTable
+		expected Symbol
+	}{
+		{global, Symbol{Name: "a", Scope: GLOBAL_SCOPE, Index: 0}},
+		{global, Symbol{Name: "b", Scope: GLOBAL_SCOPE, Index: 1, Constant: true}},
+		{local, Symbol{Name: "c", Scope: LOCAL_SCOPE, Index: 0, Constant: true}},
+		{nested, Symbol{Name: "c", Scope: FREE_SCOPE, Index: 0, Constant: true}},
+	}
+
+	for _, test := range tests {
+		symbol, ok := test.table.Resolve(test.expected.Name)
+		if !ok {
+			t.Errorf("Name %s not resolvable", test.expected.Name)
+			continue
+		}
+		if symbol != test.expected {
+			t.Errorf("Unexpected symbol for %s. Expected %+v; got %+v", test.expected.Name, test.expected, symbol)
+		}
+	}
+}
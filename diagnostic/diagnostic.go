@@ -0,0 +1,105 @@
+package diagnostic
+
+import (
+	"fmt"
+	"rowanlovejoy/monkey/parser"
+	"rowanlovejoy/monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// How serious a diagnostic is
+type Severity int
+
+const (
+	ERROR   Severity = iota // A problem that stops the program from running
+	WARNING                 // A likely mistake that doesn't stop the program from running
+)
+
+func (s Severity) String() string {
+	switch s {
+	case ERROR:
+		return "error"
+	case WARNING:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// A problem found in source, locating the span of source it concerns
+type Diagnostic struct {
+	Pos      token.Position // Start of the span
+	End      token.Position // End of the span, exclusive. May equal Pos, e.g., for an error at the end of input
+	Severity Severity
+	Code     string // Name of the check that found the problem, e.g., a lint that can be disabled, if any
+	Message  string
+}
+
+// Satisfies error interface. Formats the diagnostic as line:column: message, marking warnings as
+// line:column: warning: message and following the message with the code in parentheses, if any
+func (d Diagnostic) Error() string {
+	message := d.Message
+	if d.Code != "" {
+		message += " (" + d.Code + ")"
+	}
+
+	if d.Severity == WARNING {
+		return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, message)
+	}
+	return fmt.Sprintf("%s: %s", d.Pos, message)
+}
+
+// Convert parse errors to diagnostics spanning their offending tokens
+func FromParseErrors(errors []*parser.ParseError) []Diagnostic {
+	diagnostics := make([]Diagnostic, len(errors))
+	for i, err := range errors {
+		diagnostics[i] = Diagnostic{Pos: err.Token.Pos, End: err.Token.End, Message: err.Message}
+	}
+	return diagnostics
+}
+
+// Format d as name:line:column: message, followed by the line of source it occurs on and a marker under its
+// span. The name is omitted if empty, e.g., for input typed into the REPL
+func Format(name string, source string, d Diagnostic) string {
+	header := d.Error()
+	if name != "" {
+		header = name + ":" + header
+	}
+	return header + "\n" + Snippet(source, d.Pos, d.End)
+}
+
+// Return the line of source containing start followed by a marker under the span from start to end: a caret
+// under its first character and a tilde under each of the rest, e.g.,
+//
+//	let x == 5;
+//	      ^~
+//
+// A span running past the end of the line is cut short at it, and an empty span is marked with a lone caret.
+// Each line of the result ends in a newline
+func Snippet(source string, start token.Position, end token.Position) string {
+	offset := min(max(start.Offset, 0), len(source))
+
+	lineStart := strings.LastIndexByte(source[:offset], '\n') + 1
+	lineEnd := len(source)
+	if i := strings.IndexByte(source[offset:], '\n'); i >= 0 {
+		lineEnd = offset + i
+	}
+	line := strings.TrimSuffix(source[lineStart:lineEnd], "\r")
+
+	width := 1
+	if spanEnd := min(end.Offset, lineStart+len(line)); spanEnd > offset {
+		width = utf8.RuneCountInString(source[offset:spanEnd])
+	}
+
+	// Keep tabs before the span so the marker lines up with it however tabs are displayed
+	var padding strings.Builder
+	for _, ch := range source[lineStart:min(offset, lineStart+len(line))] {
+		if ch == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	return line + "\n" + padding.String() + "^" + strings.Repeat("~", width-1) + "\n"
+}
@@ -0,0 +1,76 @@
+package diagnostic
+
+import (
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+	"rowanlovejoy/monkey/token"
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		source   string
+		start    int
+		end      int
+		expected string
+	}{
+		{"let x = 5;", 4, 5, "let x = 5;\n    ^\n"},
+		{"x == y", 2, 4, "x == y\n  ^~\n"},
+		{"\tx @", 3, 4, "\tx @\n\t  ^\n"},
+		{"abc", 3, 3, "abc\n   ^\n"},
+		{"let a = 1;\nlet b = 2;\nlet c = 3;", 15, 16, "let b = 2;\n    ^\n"},
+		{"let s = \"é\" + 1;", 8, 12, "let s = \"é\" + 1;\n        ^~~\n"},
+		{"let s = \"a\nb\";", 8, 13, "let s = \"a\n        ^~\n"},
+		{"x;\r\ny", 0, 1, "x;\n^\n"},
+	}
+
+	for _, test := range tests {
+		start := token.Position{Offset: test.start}
+		end := token.Position{Offset: test.end}
+
+		if actual := Snippet(test.source, start, end); actual != test.expected {
+			t.Errorf("Unexpected snippet. Expected %q; got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	source := "let x = 1;\nlet = 2;"
+	p := parser.New(lexer.New(source))
+	p.ParseProgram()
+
+	diagnostics := FromParseErrors(p.Errors())
+	if len(diagnostics) != 2 {
+		t.Fatalf("Unexpected diagnostic count. Expected 2; got %d: %v", len(diagnostics), diagnostics)
+	}
+
+	expected := "a.monkey:2:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\n" +
+		"let = 2;\n" +
+		"    ^\n"
+	if actual := Format("a.monkey", source, diagnostics[0]); actual != expected {
+		t.Errorf("Unexpected format. Expected %q; got %q", expected, actual)
+	}
+
+	expected = "2:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\n" +
+		"let = 2;\n" +
+		"    ^\n"
+	if actual := Format("", source, diagnostics[0]); actual != expected {
+		t.Errorf("Unexpected format. Expected %q; got %q", expected, actual)
+	}
+}
+
+func TestFormatWarning(t *testing.T) {
+	d := Diagnostic{
+		Pos:      token.Position{Line: 1, Column: 5, Offset: 4},
+		End:      token.Position{Line: 1, Column: 6, Offset: 5},
+		Severity: WARNING,
+		Message:  "Unused binding x",
+	}
+
+	expected := "a.monkey:1:5: warning: Unused binding x\n" +
+		"let x = 1;\n" +
+		"    ^\n"
+	if actual := Format("a.monkey", "let x = 1;", d); actual != expected {
+		t.Errorf("Unexpected format. Expected %q; got %q", expected, actual)
+	}
+}
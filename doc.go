@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+	"strings"
+)
+
+// Print each top-level binding with a doc comment in the file named in args: its declaration followed by its
+// doc text, indented. Returns the process exit code
+func documentFile(args []string, out io.Writer, errOut io.Writer) int {
+	flags := flag.NewFlagSet("doc", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(errOut, "usage: monkey doc file")
+		return 2
+	}
+
+	path := flags.Arg(0)
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(errOut, "%s\n", err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+
+	if errors := p.Errors(); len(errors) > 0 {
+		printParseErrors(errOut, path, string(source), errors)
+		return 1
+	}
+
+	first := true
+	for _, statement := range program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok || len(let.Doc) == 0 {
+			continue
+		}
+
+		if !first {
+			fmt.Fprintln(out)
+		}
+		first = false
+
+		fmt.Fprintf(out, "%s %s\n", let.TokenLiteral(), let.Name.Value)
+		for _, line := range strings.Split(strings.TrimSuffix(let.DocText(), "\n"), "\n") {
+			fmt.Fprintf(out, "    %s\n", line)
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"rowanlovejoy/monkey/formatter"
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+)
+
+// Format each file named in args, printing the result or, with -w, writing it back to the file.
+// Returns the process exit code
+func formatFiles(args []string, out io.Writer, errOut io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	write := flags.Bool("w", false, "write result to source file instead of stdout")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(errOut, "usage: monkey fmt [-w] file...")
+		return 2
+	}
+
+	exitCode := 0
+
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(errOut, "%s\n", err)
+			exitCode = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(source)))
+		program := p.ParseProgram()
+
+		if errors := p.Errors(); len(errors) > 0 {
+			printParseErrors(errOut, path, string(source), errors)
+			exitCode = 1
+			continue
+		}
+
+		formatted := formatter.Format(program)
+
+		if !*write {
+			fmt.Fprint(out, formatted)
+			continue
+		}
+
+		if formatted == string(source) {
+			continue
+		}
+
+		if err := os.WriteFile(path, []byte(formatted), 0644); err != nil {
+			fmt.Fprintf(errOut, "%s\n", err)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
@@ -0,0 +1,18 @@
+package formatter
+
+import (
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/ast/printer"
+)
+
+// Unit of indentation used for each level of nesting
+const INDENT = "\t"
+
+// Preferred maximum line width, beyond which argument lists are broken one argument per line
+const WIDTH = 100
+
+// Produce canonical Monkey source for a parsed program: one statement per line, each terminated with a
+// semicolon, single spaces around infix operators, and only the parentheses required by precedence
+func Format(program *ast.Program) string {
+	return printer.Config{Indent: INDENT, Width: WIDTH}.String(program)
+}
@@ -0,0 +1,163 @@
+package formatter
+
+import (
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let   x=5",
+			"let x = 5;\n",
+		},
+		{
+			"const   x=5",
+			"const x = 5;\n",
+		},
+		{
+			"return x*y ;",
+			"return x * y;\n",
+		},
+		{
+			"a + b; c",
+			"a + b;\nc;\n",
+		},
+		{
+			"((a + b)) * c",
+			"(a + b) * c;\n",
+		},
+		{
+			"a + (b * c)",
+			"a + b * c;\n",
+		},
+		{
+			"try{risky()}catch(e){log(e)}",
+			"try {\n\trisky();\n} catch (e) {\n\tlog(e);\n}\n",
+		},
+		{
+			"(a|b) & ~c<<1",
+			"(a | b) & ~c << 1;\n",
+		},
+		{
+			"a - (b - c)",
+			"a - (b - c);\n",
+		},
+		{
+			"(a - b) - c",
+			"a - b - c;\n",
+		},
+		{
+			"-(a + b) * !c",
+			"-(a + b) * !c;\n",
+		},
+		{
+			"(1 < 2) == (3 > 4)",
+			"1 < 2 == 3 > 4;\n",
+		},
+		{
+			"x=y=x+1",
+			"x = y = x + 1;\n",
+		},
+		{
+			"a + (b = 1)",
+			"a + (b = 1);\n",
+		},
+		{
+			"x = (a ? b : (c ? d : e))",
+			"x = a ? b : c ? d : e;\n",
+		},
+		{
+			"(a ? b : c) ? d : e",
+			"(a ? b : c) ? d : e;\n",
+		},
+		{
+			"let x = null; x != (null)",
+			"let x = null;\nx != null;\n",
+		},
+		{
+			"switch(x){case 1:a;b case 2:default:c}",
+			"switch (x) {\n\tcase 1:\n\t\ta;\n\t\tb;\n\tcase 2:\n\tdefault:\n\t\tc;\n}\n",
+		},
+		{
+			"let y = switch (x) {}",
+			"let y = switch (x) {};\n",
+		},
+		{
+			"for (i in 0..n-1) { i }",
+			"for (i in 0 .. n - 1) {\n\ti;\n}\n",
+		},
+		{
+			"(1..3) * 2",
+			"(1 .. 3) * 2;\n",
+		},
+		{
+			"person . name = -(a + b).c",
+			"person.name = -(a + b).c;\n",
+		},
+		{
+			"add(1,2*3)(x)",
+			"add(1, 2 * 3)(x);\n",
+		},
+		{
+			"xs |> map(f) |> sum",
+			"sum(map(xs, f));\n",
+		},
+		{
+			"(a + b)(c); (-a).b; -a.b",
+			"(a + b)(c);\n(-a).b;\n-a.b;\n",
+		},
+		{
+			`let s = "a ${ (x+1) * 2 } b ${"c ${d}"}"`,
+			`let s = "a ${(x + 1) * 2} b ${"c ${d}"}";` + "\n",
+		},
+		{
+			"for(x in xs){}",
+			"for (x in xs) {}\n",
+		},
+		{
+			"for (x in xs) { let y = x; for (z in y) { z } }",
+			"for (x in xs) {\n\tlet y = x;\n\tfor (z in y) {\n\t\tz;\n\t}\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		p := parser.New(lexer.New(test.input))
+		program := p.ParseProgram()
+		if errors := p.Errors(); len(errors) > 0 {
+			t.Fatalf("Parser errors for %q: %v", test.input, errors)
+		}
+
+		if actual := Format(program); actual != test.expected {
+			t.Errorf("Unexpected formatted output. Expected %q; got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	input := "let a = -(1 + 2) * 3 - (4 - 5); return a / (b * c) != !d; for (x in a) { x * (x + 1); } switch (a) { case 1: b = 2; default: c } " +
+		"f(a, b,); log(\"a long argument that doesn't fit on one line\", \"and another after it\", \"and a third\", g(a,));"
+
+	p := parser.New(lexer.New(input))
+	original := p.ParseProgram()
+
+	formatted := Format(original)
+
+	reparser := parser.New(lexer.New(formatted))
+	reparsed := reparser.ParseProgram()
+	if errors := reparser.Errors(); len(errors) > 0 {
+		t.Fatalf("Formatted output %q failed to parse: %v", formatted, errors)
+	}
+
+	if original.String() != reparsed.String() {
+		t.Errorf("Formatting changed program. Expected %q; got %q", original.String(), reparsed.String())
+	}
+
+	if again := Format(reparsed); again != formatted {
+		t.Errorf("Formatting isn't idempotent. Expected %q; got %q", formatted, again)
+	}
+}
@@ -0,0 +1,105 @@
+package highlight
+
+import (
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/token"
+)
+
+// Highlight class of a span of source. Values are the names of the matching LSP semantic token types
+type Class string
+
+const (
+	KEYWORD  Class = "keyword"  // E.g., let, for, null
+	OPERATOR Class = "operator" // E.g., +, |>, ?
+	STRING   Class = "string"   // A string literal or the literal parts of an interpolated string
+	NUMBER   Class = "number"   // An integer literal
+	VARIABLE Class = "variable" // An identifier naming a binding
+	FUNCTION Class = "function" // An identifier naming a function being called, e.g., f in 'f(x)' or 'x |> f'
+	PROPERTY Class = "property" // An identifier naming a key after a dot, e.g., name in 'person.name'
+	COMMENT  Class = "comment"  // A doc comment line
+)
+
+// Every class, in a fixed order, e.g., for an LSP semantic tokens legend
+var Classes = []Class{KEYWORD, OPERATOR, STRING, NUMBER, VARIABLE, FUNCTION, PROPERTY, COMMENT}
+
+// A classified span of source
+type Span struct {
+	Class Class
+	Pos   token.Position
+	End   token.Position
+}
+
+var tokenClasses = map[token.TokenType]Class{
+	token.INT:          NUMBER,
+	token.STRING:       STRING,
+	token.STRINGHEAD:   STRING,
+	token.STRINGMIDDLE: STRING,
+	token.STRINGTAIL:   STRING,
+	token.DOCCOMMENT:   COMMENT,
+
+	token.ASSIGN:         OPERATOR,
+	token.PLUS:           OPERATOR,
+	token.MINUS:          OPERATOR,
+	token.BANG:           OPERATOR,
+	token.ASTERISK:       OPERATOR,
+	token.SLASH:          OPERATOR,
+	token.LT:             OPERATOR,
+	token.GT:             OPERATOR,
+	token.EQ:             OPERATOR,
+	token.NOTEQ:          OPERATOR,
+	token.DOTDOT:         OPERATOR,
+	token.PIPE:           OPERATOR,
+	token.AMPERSAND:      OPERATOR,
+	token.BAR:            OPERATOR,
+	token.CARET:          OPERATOR,
+	token.TILDE:          OPERATOR,
+	token.LTLT:           OPERATOR,
+	token.GTGT:           OPERATOR,
+	token.PLUSASSIGN:     OPERATOR,
+	token.MINUSASSIGN:    OPERATOR,
+	token.ASTERISKASSIGN: OPERATOR,
+	token.SLASHASSIGN:    OPERATOR,
+	token.QUESTION:       OPERATOR,
+}
+
+// Classify the tokens of input in source order for syntax highlighting, e.g., to drive LSP semantic tokens.
+// Identifiers are classified from the tokens around them, so no parse is needed and incomplete source is still
+// highlighted. Delimiters and illegal tokens aren't classified
+func Classify(input string) []Span {
+	tokens := lexer.Tokenize(input)
+	var spans []Span
+
+	for i, tok := range tokens {
+		class, ok := classify(tokens, i)
+		if ok {
+			spans = append(spans, Span{Class: class, Pos: tok.Pos, End: tok.End})
+		}
+	}
+
+	return spans
+}
+
+func classify(tokens []token.Token, i int) (Class, bool) {
+	tok := tokens[i]
+
+	if tok.Type == token.IDENT {
+		switch {
+		case i > 0 && tokens[i-1].Type == token.DOT:
+			return PROPERTY, true
+		case i+1 < len(tokens) && tokens[i+1].Type == token.LPAREN, i > 0 && tokens[i-1].Type == token.PIPE:
+			return FUNCTION, true
+		}
+		return VARIABLE, true
+	}
+
+	if class, ok := tokenClasses[tok.Type]; ok {
+		return class, true
+	}
+
+	// Keywords are the only other tokens spelled with letters
+	if tok.Literal != "" && token.LookupIdent(tok.Literal) == tok.Type {
+		return KEYWORD, true
+	}
+
+	return "", false
+}
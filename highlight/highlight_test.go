@@ -0,0 +1,42 @@
+package highlight
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	input := "/// Doc\nlet x = person.name |> trim;\nf(\"a ${b} c\", 10) ?"
+
+	expected := []struct {
+		class   Class
+		literal string
+	}{
+		{COMMENT, "/// Doc"},
+		{KEYWORD, "let"},
+		{VARIABLE, "x"},
+		{OPERATOR, "="},
+		{VARIABLE, "person"},
+		{PROPERTY, "name"},
+		{OPERATOR, "|>"},
+		{FUNCTION, "trim"},
+		{FUNCTION, "f"},
+		{STRING, "\"a ${"},
+		{VARIABLE, "b"},
+		{STRING, "} c\""},
+		{NUMBER, "10"},
+		{OPERATOR, "?"},
+	}
+
+	spans := Classify(input)
+
+	if len(spans) != len(expected) {
+		t.Fatalf("Unexpected span count. Expected %d; got %d: %+v", len(expected), len(spans), spans)
+	}
+
+	for i, span := range spans {
+		if span.Class != expected[i].class {
+			t.Errorf("spans[%d] - Unexpected class. Expected %q; got %q", i, expected[i].class, span.Class)
+		}
+		if literal := input[span.Pos.Offset:span.End.Offset]; literal != expected[i].literal {
+			t.Errorf("spans[%d] - Unexpected source. Expected %q; got %q", i, expected[i].literal, literal)
+		}
+	}
+}
@@ -1,26 +1,122 @@
 package lexer
 
-import "rowanlovejoy/monkey/token"
+import (
+	"io"
+	"rowanlovejoy/monkey/token"
+	"strings"
+)
+
+// Number of bytes requested from a reader each time a reader-backed lexer runs out of input
+const READ_CHUNK_SIZE = 4096
 
 type Lexer struct {
 	input        string
 	position     int  // Position of last read character
 	readPosition int  // Position of next character to read
 	ch           byte // Current char under examination (pointed to by position)
+	line         int  // Line of current char, counted from 1
+	column       int  // Column of current char, counted from 1
+	discarded    int  // Bytes of input dropped before position, so that offsets count from the start of the source
+
+	// Brace depth within each interpolation of the string currently being lexed, innermost last.
+	// A } at depth zero closes the interpolation and resumes the string
+	interpolations []int
+
+	reader  io.Reader       // Source of further input, if reading incrementally; nil once exhausted
+	readErr error           // Error other than io.EOF that stopped reading from reader
+	held    strings.Builder // Input read from reader, of which input is the unconsumed end
+
+	trivia bool // Whether whitespace and a shebang line are produced as tokens rather than skipped
+}
+
+// Configures optional Lexer behaviour when passed to New or NewFromReader
+type Option func(*Lexer)
+
+// Produce the whitespace between tokens and any shebang line as WHITESPACE and SHEBANG tokens rather than
+// skipping them, so that the tokens cover the whole source without gaps: each begins where the one before it
+// ends. Lossless tools like refactorings can then reconstruct the exact source from the tokens' spans
+func WithTrivia() Option {
+	return func(l *Lexer) {
+		l.trivia = true
+	}
+}
+
+// Lex input as the part of a larger source beginning at start, so that tokens have their positions in the
+// whole source, e.g., to re-lex only the text following an edit. start must not be within a string
+func WithStart(start token.Position) Option {
+	return func(l *Lexer) {
+		l.line = start.Line
+		l.column = start.Column - 1
+		l.discarded = start.Offset
+	}
 }
 
 // Create and initialise a new Lexer instance with first input char already read
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+func New(input string, options ...Option) *Lexer {
+	l := &Lexer{input: input, line: 1}
+	for _, option := range options {
+		option(l)
+	}
+	l.readChar()
+	l.skipShebang()
+	return l
+}
+
+// Create a Lexer that reads its input incrementally from r, holding only the unconsumed input in memory
+// rather than the whole source. Lexing stops at the first read error, which is then available from Err
+func NewFromReader(r io.Reader, options ...Option) *Lexer {
+	l := &Lexer{reader: r, line: 1}
+	for _, option := range options {
+		option(l)
+	}
 	l.readChar()
+	l.skipShebang()
 	return l
 }
 
+// Return the error that stopped a reader-backed lexer reading its input early, if any
+func (l *Lexer) Err() error {
+	return l.readErr
+}
+
+// Lex the whole of input, returning every token up to but not including EOF. Illegal tokens are included
+func Tokenize(input string) []token.Token {
+	var tokens []token.Token
+
+	New(input).All()(func(tok token.Token) bool {
+		tokens = append(tokens, tok)
+		return true
+	})
+
+	return tokens
+}
+
+// Return an iterator over the lexer's remaining tokens up to but not including EOF, for use as a range-over-func
+// sequence, e.g., 'for tok := range l.All()'. Stops early if yield returns false
+func (l *Lexer) All() func(yield func(token.Token) bool) {
+	return func(yield func(token.Token) bool) {
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if !yield(tok) {
+				return
+			}
+		}
+	}
+}
+
 // Return the token corresponding to the current char and then advance the lexer
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
+	if l.trivia {
+		if tok, ok := l.readTrivia(); ok {
+			return tok
+		}
+	}
+
 	l.skipWhitespace()
+	l.discardConsumed()
+
+	position := l.currentPosition()
 
 	switch l.ch {
 	case '=':
@@ -30,9 +126,17 @@ func (l *Lexer) NextToken() token.Token {
 			tok = token.New(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = token.New(token.PLUS, l.ch)
+		if literal, ok := l.makeTwoCharLiteral("+="); ok {
+			tok = token.Token{Type: token.PLUSASSIGN, Literal: literal}
+		} else {
+			tok = token.New(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = token.New(token.MINUS, l.ch)
+		if literal, ok := l.makeTwoCharLiteral("-="); ok {
+			tok = token.Token{Type: token.MINUSASSIGN, Literal: literal}
+		} else {
+			tok = token.New(token.MINUS, l.ch)
+		}
 	case '!':
 		if literal, ok := l.makeTwoCharLiteral("!="); ok {
 			tok = token.Token{Type: token.NOTEQ, Literal: literal}
@@ -40,13 +144,53 @@ func (l *Lexer) NextToken() token.Token {
 			tok = token.New(token.BANG, l.ch)
 		}
 	case '/':
-		tok = token.New(token.SLASH, l.ch)
+		if l.peekChar() == '/' && l.peekCharAt(2) == '/' {
+			tok.Type = token.DOCCOMMENT
+			tok.Literal = l.readLine()
+			tok.Pos = position
+			tok.End = l.currentPosition()
+			return tok
+		} else if literal, ok := l.makeTwoCharLiteral("/="); ok {
+			tok = token.Token{Type: token.SLASHASSIGN, Literal: literal}
+		} else {
+			tok = token.New(token.SLASH, l.ch)
+		}
 	case '*':
-		tok = token.New(token.ASTERISK, l.ch)
+		if literal, ok := l.makeTwoCharLiteral("*="); ok {
+			tok = token.Token{Type: token.ASTERISKASSIGN, Literal: literal}
+		} else {
+			tok = token.New(token.ASTERISK, l.ch)
+		}
 	case '<':
-		tok = token.New(token.LT, l.ch)
+		if literal, ok := l.makeTwoCharLiteral("<<"); ok {
+			tok = token.Token{Type: token.LTLT, Literal: literal}
+		} else {
+			tok = token.New(token.LT, l.ch)
+		}
 	case '>':
-		tok = token.New(token.GT, l.ch)
+		if literal, ok := l.makeTwoCharLiteral(">>"); ok {
+			tok = token.Token{Type: token.GTGT, Literal: literal}
+		} else {
+			tok = token.New(token.GT, l.ch)
+		}
+	case '&':
+		tok = token.New(token.AMPERSAND, l.ch)
+	case '^':
+		tok = token.New(token.CARET, l.ch)
+	case '~':
+		tok = token.New(token.TILDE, l.ch)
+	case '.':
+		if literal, ok := l.makeTwoCharLiteral(".."); ok {
+			tok = token.Token{Type: token.DOTDOT, Literal: literal}
+		} else {
+			tok = token.New(token.DOT, l.ch)
+		}
+	case '|':
+		if literal, ok := l.makeTwoCharLiteral("|>"); ok {
+			tok = token.Token{Type: token.PIPE, Literal: literal}
+		} else {
+			tok = token.New(token.BAR, l.ch)
+		}
 	case ',':
 		tok = token.New(token.COMMA, l.ch)
 	case ';':
@@ -55,37 +199,85 @@ func (l *Lexer) NextToken() token.Token {
 		tok = token.New(token.LPAREN, l.ch)
 	case ')':
 		tok = token.New(token.RPAREN, l.ch)
+	case '[':
+		tok = token.New(token.LBRACKET, l.ch)
+	case ']':
+		tok = token.New(token.RBRACKET, l.ch)
 	case '{':
+		if depth := len(l.interpolations); depth > 0 {
+			l.interpolations[depth-1] += 1
+		}
 		tok = token.New(token.LBRACE, l.ch)
 	case '}':
-		tok = token.New(token.RBRACE, l.ch)
+		if depth := len(l.interpolations); depth > 0 && l.interpolations[depth-1] == 0 {
+			l.interpolations = l.interpolations[:depth-1]
+			tok = l.readStringPart(false)
+		} else {
+			if depth > 0 {
+				l.interpolations[depth-1] -= 1
+			}
+			tok = token.New(token.RBRACE, l.ch)
+		}
+	case '"':
+		tok = l.readStringPart(true)
+	case '?':
+		tok = token.New(token.QUESTION, l.ch)
+	case ':':
+		tok = token.New(token.COLON, l.ch)
 	case 0:
-		tok.Literal = ""
-		tok.Type = token.EOF
+		// A NUL byte within the input is illegal rather than the end of it
+		if l.atEOF() {
+			tok.Literal = ""
+			tok.Type = token.EOF
+		} else {
+			tok = token.New(token.ILLEGAL, l.ch)
+		}
 	default:
 		// Letter and digit branches exit early due to having already advanced the lexer
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = position
+			tok.End = l.currentPosition()
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Pos = position
+			tok.End = l.currentPosition()
 			return tok
 		} else {
 			tok = token.New(token.ILLEGAL, l.ch)
 		}
 	}
 
+	tok.Pos = position
+
+	// EOF has no chars, so it ends where it begins, and an unterminated string has consumed the rest of the input
+	if l.atEOF() {
+		tok.End = l.currentPosition()
+		return tok
+	}
+
 	l.readChar()
+	tok.End = l.currentPosition()
 	return tok
 }
 
+// Get the position of the current char
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Line: l.line, Column: l.column, Offset: l.discarded + l.position}
+}
+
+// Report whether the lexer has consumed all of its input
+func (l *Lexer) atEOF() bool {
+	return l.position >= len(l.input)
+}
+
 // Attempt to construct the specified two char literal from the current and next char, advancing lexer if successful
 func (l *Lexer) makeTwoCharLiteral(expected string) (string, bool) {
-	nextCh := l.peekChar()
-	literal := string(l.ch) + string(nextCh)
-	if literal == expected {
+	// Compare chars individually rather than building the literal, which would allocate for every operator
+	if l.ch == expected[0] && l.peekChar() == expected[1] {
 		l.readChar()
 		return expected, true
 	} else {
@@ -93,12 +285,109 @@ func (l *Lexer) makeTwoCharLiteral(expected string) (string, bool) {
 	}
 }
 
+// Read string contents from the current " or interpolation-closing } up to the closing " or the next ${,
+// leaving the lexer on the " or {. The escape sequences \", \$, and \\ stand for the char following the
+// backslash, so that a string may contain a " or ${; the literal holds the contents with them replaced. Other
+// backslashes are kept as written. Unterminated strings produce an ILLEGAL token
+func (l *Lexer) readStringPart(isStart bool) token.Token {
+	position := l.position + 1
+	escaped := false
+
+	for {
+		l.readChar()
+
+		switch {
+		case l.atEOF():
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[position-1 : l.position]}
+		case l.ch == '\\' && l.peekChar() != 0:
+			// Skip the escaped char, so that an escaped " or $ neither ends the string nor begins an interpolation
+			escaped = true
+			l.readChar()
+		case l.ch == '"':
+			literal := l.stringContents(position, escaped)
+			if isStart {
+				return token.Token{Type: token.STRING, Literal: literal}
+			}
+			return token.Token{Type: token.STRINGTAIL, Literal: literal}
+		case l.ch == '$' && l.peekChar() == '{':
+			literal := l.stringContents(position, escaped)
+			l.readChar()
+			l.interpolations = append(l.interpolations, 0)
+			if isStart {
+				return token.Token{Type: token.STRINGHEAD, Literal: literal}
+			}
+			return token.Token{Type: token.STRINGMIDDLE, Literal: literal}
+		}
+	}
+}
+
+// Get the contents of a string from position up to the current char, replacing any escape sequences in them
+func (l *Lexer) stringContents(position int, escaped bool) string {
+	contents := l.input[position:l.position]
+	if !escaped {
+		return contents
+	}
+
+	var out strings.Builder
+	out.Grow(len(contents))
+
+	for i := 0; i < len(contents); i++ {
+		if contents[i] == '\\' && i+1 < len(contents) && strings.IndexByte(`"$\`, contents[i+1]) >= 0 {
+			i += 1
+		}
+		out.WriteByte(contents[i])
+	}
+
+	return out.String()
+}
+
+// Skip a '#!' line at the start of the input, e.g., '#!/usr/bin/env monkey', so that scripts can be run directly
+// on Unix. Later lines keep their numbers. The line is left for readTrivia when producing trivia
+func (l *Lexer) skipShebang() {
+	if l.trivia || !l.atShebang() {
+		return
+	}
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
+// Report whether the current char begins a shebang line at the start of the input
+func (l *Lexer) atShebang() bool {
+	return l.discarded+l.position == 0 && l.ch == '#' && l.peekChar() == '!'
+}
+
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for isWhitespace(l.ch) {
 		l.readChar()
 	}
 }
 
+// Read the whitespace or shebang line at the current char as a token, reporting false if there's none
+func (l *Lexer) readTrivia() (token.Token, bool) {
+	l.discardConsumed()
+
+	position := l.currentPosition()
+	start := l.position
+
+	var tokenType token.TokenType
+	switch {
+	case l.atShebang():
+		tokenType = token.SHEBANG
+		for l.ch != '\n' && !l.atEOF() {
+			l.readChar()
+		}
+	case isWhitespace(l.ch):
+		tokenType = token.WHITESPACE
+		l.skipWhitespace()
+	default:
+		return token.Token{}, false
+	}
+
+	return token.Token{Type: tokenType, Literal: l.input[start:l.position], Pos: position, End: l.currentPosition()}, true
+}
+
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 	for isLetter(l.ch) {
@@ -107,7 +396,69 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
+// Drop input before the current char when reading incrementally. Only called between tokens, as reading
+// a token keeps offsets into the input
+func (l *Lexer) discardConsumed() {
+	if l.reader == nil || l.position == 0 {
+		return
+	}
+
+	l.discarded += l.position
+	l.input = l.input[l.position:]
+	l.readPosition -= l.position
+	l.position = 0
+
+	// Once most of what's held has been consumed, copy the rest afresh so the consumed input can be freed. The
+	// copy is smaller than what was consumed, so copying stays linear in the length of the source
+	if l.held.Len() > 2*len(l.input)+READ_CHUNK_SIZE {
+		l.held.Reset()
+		l.held.WriteString(l.input)
+		l.input = l.held.String()
+	}
+}
+
+// Append the next chunk of input from the reader, if any remains
+func (l *Lexer) fill() {
+	if l.reader == nil {
+		return
+	}
+
+	buffer := make([]byte, READ_CHUNK_SIZE)
+
+	for l.reader != nil {
+		n, err := l.reader.Read(buffer)
+
+		// Appending to the builder copies only the new bytes, amortised, and taking its string copies nothing,
+		// whereas appending to input would copy all of it, making a long token quadratic to read
+		l.held.Write(buffer[:n])
+		held := l.held.String()
+		l.input = held[len(held)-len(l.input)-n:]
+
+		if err != nil {
+			if err != io.EOF {
+				l.readErr = err
+			}
+			l.reader = nil
+		}
+
+		if n > 0 {
+			return
+		}
+	}
+}
+
 func (l *Lexer) readChar() {
+	if l.readPosition >= len(l.input) {
+		l.fill()
+	}
+
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 1
+	} else {
+		l.column += 1
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // ASCII code for the "NUL" char, represents EOF
 	} else {
@@ -117,6 +468,15 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 }
 
+// Read up to the end of the current line, leaving the lexer on the newline
+func (l *Lexer) readLine() string {
+	position := l.position
+	for l.ch != '\n' && !l.atEOF() {
+		l.readChar()
+	}
+	return strings.TrimSuffix(l.input[position:l.position], "\r")
+}
+
 func (l *Lexer) readNumber() string {
 	position := l.position
 	for isDigit(l.ch) {
@@ -127,13 +487,28 @@ func (l *Lexer) readNumber() string {
 
 // Return the next char to be read without advancing the lexer
 func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+	return l.peekCharAt(1)
+}
+
+// Return the char n chars after the current one without advancing the lexer
+func (l *Lexer) peekCharAt(n int) byte {
+	position := l.position + n
+
+	for position >= len(l.input) && l.reader != nil {
+		l.fill()
+	}
+
+	if position >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		return l.input[position]
 	}
 }
 
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
 func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
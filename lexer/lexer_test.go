@@ -1,8 +1,15 @@
 package lexer
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"rowanlovejoy/monkey/token"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNextToken(t *testing.T) {
@@ -26,6 +33,22 @@ func TestNextToken(t *testing.T) {
 
 		10 == 10;
 		10 != 9;
+		for (x in xs) {}
+		x += 1; x -= 1; x *= 1; x /= 1;
+		a ? b : c
+		null
+		const
+		switch case default
+		1..10
+		a.b
+		x |> f
+		a & b | c ^ ~d << 1 >> 2
+		import "lib"
+		try catch
+		break continue
+		/// Documents x
+		a // b
+		s[1:]
 	`
 
 	tests := []struct {
@@ -105,6 +128,77 @@ func TestNextToken(t *testing.T) {
 		{token.NOTEQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.IN, "in"},
+		{token.IDENT, "xs"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "x"},
+		{token.PLUSASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUSASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISKASSIGN, "*="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASHASSIGN, "/="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.QUESTION, "?"},
+		{token.IDENT, "b"},
+		{token.COLON, ":"},
+		{token.IDENT, "c"},
+		{token.NULL, "null"},
+		{token.CONST, "const"},
+		{token.SWITCH, "switch"},
+		{token.CASE, "case"},
+		{token.DEFAULT, "default"},
+		{token.INT, "1"},
+		{token.DOTDOT, ".."},
+		{token.INT, "10"},
+		{token.IDENT, "a"},
+		{token.DOT, "."},
+		{token.IDENT, "b"},
+		{token.IDENT, "x"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.IDENT, "a"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "b"},
+		{token.BAR, "|"},
+		{token.IDENT, "c"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.IDENT, "d"},
+		{token.LTLT, "<<"},
+		{token.INT, "1"},
+		{token.GTGT, ">>"},
+		{token.INT, "2"},
+		{token.IMPORT, "import"},
+		{token.STRING, "lib"},
+		{token.TRY, "try"},
+		{token.CATCH, "catch"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.DOCCOMMENT, "/// Documents x"},
+		{token.IDENT, "a"},
+		{token.SLASH, "/"},
+		{token.SLASH, "/"},
+		{token.IDENT, "b"},
+		{token.IDENT, "s"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.RBRACKET, "]"},
 		{token.EOF, ""},
 	}
 
@@ -124,3 +218,488 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x == 10;"
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedPosition token.Position
+		expectedEnd      token.Position
+	}{
+		{token.LET, token.Position{Line: 1, Column: 1, Offset: 0}, token.Position{Line: 1, Column: 4, Offset: 3}},
+		{token.IDENT, token.Position{Line: 1, Column: 5, Offset: 4}, token.Position{Line: 1, Column: 6, Offset: 5}},
+		{token.ASSIGN, token.Position{Line: 1, Column: 7, Offset: 6}, token.Position{Line: 1, Column: 8, Offset: 7}},
+		{token.INT, token.Position{Line: 1, Column: 9, Offset: 8}, token.Position{Line: 1, Column: 10, Offset: 9}},
+		{token.SEMICOLON, token.Position{Line: 1, Column: 10, Offset: 9}, token.Position{Line: 1, Column: 11, Offset: 10}},
+		{token.IDENT, token.Position{Line: 2, Column: 3, Offset: 13}, token.Position{Line: 2, Column: 4, Offset: 14}},
+		{token.EQ, token.Position{Line: 2, Column: 5, Offset: 15}, token.Position{Line: 2, Column: 7, Offset: 17}},
+		{token.INT, token.Position{Line: 2, Column: 8, Offset: 18}, token.Position{Line: 2, Column: 10, Offset: 20}},
+		{token.SEMICOLON, token.Position{Line: 2, Column: 10, Offset: 20}, token.Position{Line: 2, Column: 11, Offset: 21}},
+		{token.EOF, token.Position{Line: 2, Column: 11, Offset: 21}, token.Position{Line: 2, Column: 11, Offset: 21}},
+	}
+
+	for _, l := range []*Lexer{New(input), NewFromReader(iotest.OneByteReader(strings.NewReader(input)))} {
+		for i, tt := range tests {
+			tok := l.NextToken()
+
+			if tok.Type != tt.expectedType {
+				t.Fatalf("tests[%d] - unexpected token type. expected=%q, got=%q",
+					i, tt.expectedType, tok.Type)
+			}
+
+			if tok.Pos != tt.expectedPosition {
+				t.Fatalf("tests[%d] - unexpected position. expected=%+v, got=%+v",
+					i, tt.expectedPosition, tok.Pos)
+			}
+
+			if tok.End != tt.expectedEnd {
+				t.Fatalf("tests[%d] - unexpected end position. expected=%+v, got=%+v",
+					i, tt.expectedEnd, tok.End)
+			}
+		}
+	}
+}
+
+func TestStringTokens(t *testing.T) {
+	type expectedToken struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}
+
+	tests := []struct {
+		input    string
+		expected []expectedToken
+	}{
+		{
+			`"foo bar"`,
+			[]expectedToken{{token.STRING, "foo bar"}},
+		},
+		{
+			`""`,
+			[]expectedToken{{token.STRING, ""}},
+		},
+		{
+			`"total: ${x + y}!"`,
+			[]expectedToken{
+				{token.STRINGHEAD, "total: "},
+				{token.IDENT, "x"},
+				{token.PLUS, "+"},
+				{token.IDENT, "y"},
+				{token.STRINGTAIL, "!"},
+			},
+		},
+		{
+			`"${a} and ${b}"`,
+			[]expectedToken{
+				{token.STRINGHEAD, ""},
+				{token.IDENT, "a"},
+				{token.STRINGMIDDLE, " and "},
+				{token.IDENT, "b"},
+				{token.STRINGTAIL, ""},
+			},
+		},
+		{
+			`"a ${ "b ${c}" } d"`,
+			[]expectedToken{
+				{token.STRINGHEAD, "a "},
+				{token.STRINGHEAD, "b "},
+				{token.IDENT, "c"},
+				{token.STRINGTAIL, ""},
+				{token.STRINGTAIL, " d"},
+			},
+		},
+		{
+			`"${ switch (x) { default: 1 } }"`,
+			[]expectedToken{
+				{token.STRINGHEAD, ""},
+				{token.SWITCH, "switch"},
+				{token.LPAREN, "("},
+				{token.IDENT, "x"},
+				{token.RPAREN, ")"},
+				{token.LBRACE, "{"},
+				{token.DEFAULT, "default"},
+				{token.COLON, ":"},
+				{token.INT, "1"},
+				{token.RBRACE, "}"},
+				{token.STRINGTAIL, ""},
+			},
+		},
+		{
+			`"$ {x} $x"`,
+			[]expectedToken{{token.STRING, "$ {x} $x"}},
+		},
+		{
+			`"say \"hi\""`,
+			[]expectedToken{{token.STRING, `say "hi"`}},
+		},
+		{
+			`"\${x} costs \$5 ${y}\\"`,
+			[]expectedToken{
+				{token.STRINGHEAD, "${x} costs $5 "},
+				{token.IDENT, "y"},
+				{token.STRINGTAIL, `\`},
+			},
+		},
+		{
+			`"a\\" b`,
+			[]expectedToken{{token.STRING, `a\`}, {token.IDENT, "b"}},
+		},
+		{
+			`"C:\dir\n"`,
+			[]expectedToken{{token.STRING, `C:\dir\n`}},
+		},
+		{
+			`"unterminated`,
+			[]expectedToken{{token.ILLEGAL, `"unterminated`}},
+		},
+		{
+			`"trailing \`,
+			[]expectedToken{{token.ILLEGAL, `"trailing \`}},
+		},
+		{
+			`"escaped \"`,
+			[]expectedToken{{token.ILLEGAL, `"escaped \"`}},
+		},
+		{
+			`"a ${x`,
+			[]expectedToken{
+				{token.STRINGHEAD, "a "},
+				{token.IDENT, "x"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		l := New(test.input)
+
+		for i, expected := range append(test.expected, expectedToken{token.EOF, ""}) {
+			tok := l.NextToken()
+
+			if tok.Type != expected.expectedType {
+				t.Fatalf("%s tokens[%d] - unexpected token type. expected=%q, got=%q",
+					test.input, i, expected.expectedType, tok.Type)
+			}
+
+			if tok.Literal != expected.expectedLiteral {
+				t.Fatalf("%s tokens[%d] - unexpected literal. expected=%q, got=%q",
+					test.input, i, expected.expectedLiteral, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestNewFromReader(t *testing.T) {
+	input := `
+		let add = fn(x, y) {
+			x + y;
+		};
+		let s = "total: ${add(1, 2)}";
+		10 != 9 == true;
+	`
+
+	readers := map[string]io.Reader{
+		"whole":    strings.NewReader(input),
+		"one byte": iotest.OneByteReader(strings.NewReader(input)),
+		"half":     iotest.HalfReader(strings.NewReader(input)),
+	}
+
+	for name, reader := range readers {
+		expected := New(input)
+		actual := NewFromReader(reader)
+
+		for i := 0; ; i++ {
+			expectedToken := expected.NextToken()
+			actualToken := actual.NextToken()
+
+			if actualToken != expectedToken {
+				t.Fatalf("%s reader tokens[%d] - unexpected token. expected=%+v, got=%+v", name, i, expectedToken, actualToken)
+			}
+
+			if expectedToken.Type == token.EOF {
+				break
+			}
+		}
+
+		if err := actual.Err(); err != nil {
+			t.Errorf("%s reader - unexpected error: %s", name, err)
+		}
+	}
+}
+
+func TestShebang(t *testing.T) {
+	input := "#!/usr/bin/env monkey\nlet x = 1;\n"
+
+	tests := []token.Token{
+		{Type: token.LET, Literal: "let", Pos: token.Position{Line: 2, Column: 1, Offset: 22}, End: token.Position{Line: 2, Column: 4, Offset: 25}},
+		{Type: token.IDENT, Literal: "x", Pos: token.Position{Line: 2, Column: 5, Offset: 26}, End: token.Position{Line: 2, Column: 6, Offset: 27}},
+	}
+
+	lexers := map[string]*Lexer{
+		"string": New(input),
+		"reader": NewFromReader(iotest.OneByteReader(strings.NewReader(input))),
+	}
+
+	for name, l := range lexers {
+		for i, expected := range tests {
+			if tok := l.NextToken(); tok != expected {
+				t.Fatalf("%s lexer tokens[%d] - unexpected token. expected=%+v, got=%+v", name, i, expected, tok)
+			}
+		}
+	}
+
+	// Only a shebang at the very start of the input is skipped
+	l := New("x\n#!")
+	for i, expectedType := range []token.TokenType{token.IDENT, token.ILLEGAL, token.BANG, token.EOF} {
+		if tok := l.NextToken(); tok.Type != expectedType {
+			t.Fatalf("tokens[%d] - unexpected token type. expected=%q, got=%q", i, expectedType, tok.Type)
+		}
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	input := "#!/usr/bin/env monkey\r\n/// Doc\nlet s = \"a ${ x }\";\t\r\n  @  "
+
+	expected := []token.TokenType{
+		token.SHEBANG, token.WHITESPACE, token.DOCCOMMENT, token.WHITESPACE, token.LET, token.WHITESPACE, token.IDENT,
+		token.WHITESPACE, token.ASSIGN, token.WHITESPACE, token.STRINGHEAD, token.WHITESPACE, token.IDENT,
+		token.WHITESPACE, token.STRINGTAIL, token.SEMICOLON, token.WHITESPACE, token.ILLEGAL, token.WHITESPACE, token.EOF,
+	}
+
+	lexers := map[string]*Lexer{
+		"string": New(input, WithTrivia()),
+		"reader": NewFromReader(iotest.OneByteReader(strings.NewReader(input)), WithTrivia()),
+	}
+
+	for name, l := range lexers {
+		var source strings.Builder
+
+		for i, expectedType := range expected {
+			tok := l.NextToken()
+			if tok.Type != expectedType {
+				t.Fatalf("%s lexer tokens[%d] - unexpected token type. expected=%q, got=%q", name, i, expectedType, tok.Type)
+			}
+
+			// The tokens cover the source without gaps or overlaps
+			if tok.Pos.Offset != source.Len() {
+				t.Fatalf("%s lexer tokens[%d] - unexpected offset. expected=%d, got=%d", name, i, source.Len(), tok.Pos.Offset)
+			}
+			source.WriteString(input[tok.Pos.Offset:tok.End.Offset])
+		}
+
+		if source.String() != input {
+			t.Errorf("%s lexer - unexpected source. expected=%q, got=%q", name, input, source.String())
+		}
+	}
+
+	if tok := New("  ", WithTrivia()).NextToken(); tok.Type != token.WHITESPACE || tok.Literal != "  " {
+		t.Errorf("Unexpected token. Expected WHITESPACE %q; got %s %q", "  ", tok.Type, tok.Literal)
+	}
+}
+
+func TestNULByte(t *testing.T) {
+	l := New("x\x00y")
+
+	for i, expectedType := range []token.TokenType{token.IDENT, token.ILLEGAL, token.IDENT, token.EOF} {
+		if tok := l.NextToken(); tok.Type != expectedType {
+			t.Fatalf("tokens[%d] - unexpected token type. expected=%q, got=%q", i, expectedType, tok.Type)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	input := "let x = @;"
+	tokens := Tokenize(input)
+
+	l := New(input)
+	for i, tok := range tokens {
+		if expected := l.NextToken(); tok != expected {
+			t.Fatalf("tokens[%d] - unexpected token. expected=%+v, got=%+v", i, expected, tok)
+		}
+	}
+
+	// Illegal tokens are included but EOF isn't
+	if len(tokens) != 5 || tokens[3].Type != token.ILLEGAL {
+		t.Errorf("Unexpected tokens. Expected 5 ending in ILLEGAL and SEMICOLON; got %+v", tokens)
+	}
+
+	if tokens := Tokenize(""); len(tokens) != 0 {
+		t.Errorf("Unexpected tokens for empty input. Expected none; got %+v", tokens)
+	}
+}
+
+func TestAll(t *testing.T) {
+	l := New("a b c d")
+	var literals []string
+
+	// Stopping early leaves the remaining tokens unread
+	l.All()(func(tok token.Token) bool {
+		literals = append(literals, tok.Literal)
+		return len(literals) < 2
+	})
+
+	if strings.Join(literals, " ") != "a b" {
+		t.Errorf("Unexpected tokens. Expected %q; got %q", "a b", literals)
+	}
+
+	if tok := l.NextToken(); tok.Literal != "c" {
+		t.Errorf("Unexpected next token. Expected %q; got %q", "c", tok.Literal)
+	}
+}
+
+func TestNewFromReaderError(t *testing.T) {
+	readErr := errors.New("disk on fire")
+	reader := io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(readErr))
+
+	l := NewFromReader(reader)
+
+	tests := []token.TokenType{token.LET, token.IDENT, token.EOF}
+	for i, expectedType := range tests {
+		if tok := l.NextToken(); tok.Type != expectedType {
+			t.Fatalf("tokens[%d] - unexpected token type. expected=%q, got=%q", i, expectedType, tok.Type)
+		}
+	}
+
+	if err := l.Err(); err != readErr {
+		t.Errorf("Unexpected error. Expected %q; got %v", readErr, err)
+	}
+}
+
+// Reading incrementally holds on to little more than the token being read: a long token is held whole while it's
+// read, and the input it was read from is freed once the lexer moves past it
+func TestNewFromReaderHeldInput(t *testing.T) {
+	contents := strings.Repeat("a", 1<<20)
+	input := "let s = \"" + contents + "\";" + strings.Repeat(" s;", 4*READ_CHUNK_SIZE)
+
+	l := NewFromReader(strings.NewReader(input))
+	var literal string
+	held := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.STRING {
+			literal = tok.Literal
+		}
+		held = max(held, l.held.Len())
+	}
+
+	if literal != contents {
+		t.Errorf("Unexpected string literal. Expected %d bytes; got %d", len(contents), len(literal))
+	}
+	if limit := len(contents) + 2*READ_CHUNK_SIZE; held > limit {
+		t.Errorf("Unexpected input held. Expected at most %d bytes; got %d", limit, held)
+	}
+	if limit := 3 * READ_CHUNK_SIZE; l.held.Len() > limit {
+		t.Errorf("Unexpected input held at the end. Expected at most %d bytes; got %d", limit, l.held.Len())
+	}
+}
+
+// Read the program shared by the lexer and parser benchmarks, repeated to several megabytes. It exercises most
+// kinds of token, expression, and statement
+func largeProgram(b *testing.B) string {
+	source, err := os.ReadFile(filepath.Join("..", "testdata", "large.monkey"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return strings.Repeat(string(source), 20000)
+}
+
+func BenchmarkLexLargeProgram(b *testing.B) {
+	input := largeProgram(b)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := New(input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+	}
+}
+
+// Reading a token spanning many chunks takes time linear in its length, so the time per byte stays level as the
+// token grows rather than rising with it
+func BenchmarkNewFromReaderLongToken(b *testing.B) {
+	for _, size := range []int{1 << 12, 1 << 16, 1 << 20} {
+		input := "\"" + strings.Repeat("a", size) + "\""
+
+		b.Run(fmt.Sprintf("%d bytes", size), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				l := NewFromReader(strings.NewReader(input))
+				for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkNextToken(b *testing.B) {
+	input := strings.Repeat(`
+		let add = fn(x, y) { return x + y; };
+		let result = add(five, ten);
+		if (5 < 10) { return true; } else { return false; }
+		!-/*5; 10 == 10; 10 != 9;
+	`, 100)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		l := New(input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+	}
+}
+
+func FuzzNextToken(f *testing.F) {
+	for _, seed := range []string{
+		"let five = 5; let add = fn(x, y) { x + y; };",
+		`"a ${b} c ${ {d: "${e}"} } f"`,
+		"x |> f | g & h ^ ~i << 2 >> 1",
+		"#!/usr/bin/env monkey\n1..10",
+		`"unterminated ${`,
+		"\x00\xff@",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		expected := New(input)
+		actual := NewFromReader(iotest.OneByteReader(strings.NewReader(input)))
+		previous := token.Position{}
+
+		// With trivia, the tokens cover the input exactly
+		var covered strings.Builder
+		trivia := New(input, WithTrivia())
+		for i, tok := 0, trivia.NextToken(); tok.Type != token.EOF && i <= len(input); i, tok = i+1, trivia.NextToken() {
+			if tok.Pos.Offset != covered.Len() {
+				t.Fatalf("Trivia token out of place. expected offset=%d, got=%+v", covered.Len(), tok)
+			}
+			covered.WriteString(input[tok.Pos.Offset:tok.End.Offset])
+		}
+		if covered.String() != input {
+			t.Fatalf("Trivia tokens failed to cover input. expected=%q, got=%q", input, covered.String())
+		}
+
+		// Every token but EOF consumes at least one byte, so anything more means the lexer is stuck
+		for i := 0; i <= len(input); i++ {
+			tok := expected.NextToken()
+
+			if tok.Pos.Offset < previous.Offset || tok.End.Offset < tok.Pos.Offset || tok.End.Offset > len(input) {
+				t.Fatalf("tokens[%d] - token out of place. previous end=%+v, got=%+v", i, previous, tok)
+			}
+			previous = tok.End
+
+			if readerToken := actual.NextToken(); readerToken != tok {
+				t.Fatalf("tokens[%d] - reader lexer disagrees. expected=%+v, got=%+v", i, tok, readerToken)
+			}
+
+			if tok.Type == token.EOF {
+				return
+			}
+		}
+
+		t.Fatalf("Lexer failed to reach EOF after %d tokens of %q", len(input)+1, input)
+	})
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"rowanlovejoy/monkey/lsp"
+)
+
+// Serve the Language Server Protocol over in and out for an editor, returning the process exit code
+func serveLanguageServer(in io.Reader, out io.Writer, errOut io.Writer) int {
+	if err := lsp.Serve(in, out); err != nil {
+		fmt.Fprintf(errOut, "lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
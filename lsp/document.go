@@ -0,0 +1,287 @@
+package lsp
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"rowanlovejoy/monkey/analysis"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/ast/printer"
+	"rowanlovejoy/monkey/diagnostic"
+	"rowanlovejoy/monkey/highlight"
+	"rowanlovejoy/monkey/module"
+	"rowanlovejoy/monkey/parser"
+	"rowanlovejoy/monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// An open source file, parsed along with the binding each of its identifiers refers to
+type document struct {
+	text     string
+	program  *ast.Program
+	errors   []*parser.ParseError
+	problems []diagnostic.Diagnostic // Found by analysis, only once the document parses without errors
+
+	resolution *analysis.Resolution
+
+	parsed *parser.Document // Source of text, program, and errors, to which later changes are applied
+}
+
+func newDocument(uri string, parsed *parser.Document) *document {
+	program := parsed.Program
+
+	d := &document{
+		text:       parsed.Source,
+		program:    program,
+		errors:     parsed.Errors,
+		resolution: analysis.Resolve(program),
+		parsed:     parsed,
+	}
+
+	if len(d.errors) == 0 {
+		// Without the names its imports bind, references to them would be reported as undefined
+		if imported, ok := importedNames(uri, program); ok {
+			d.problems = analysis.Check(program, analysis.WithPredeclared(imported...))
+		}
+	}
+
+	return d
+}
+
+// Get the names bound at the top level of the modules a program imports, loading them relative to the file
+// at uri. Reports false if the program imports anything but uri isn't a file or an import fails to load
+func importedNames(uri string, program *ast.Program) ([]string, bool) {
+	var paths []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if statement, ok := node.(*ast.ImportStatement); ok {
+			paths = append(paths, statement.Path.Value)
+		}
+		return true
+	})
+
+	if len(paths) == 0 {
+		return nil, true
+	}
+
+	location, err := url.Parse(uri)
+	if err != nil || location.Scheme != "file" {
+		return nil, false
+	}
+
+	var names []string
+	loader := module.NewLoader()
+
+	for _, path := range paths {
+		imported, err := loader.Load(module.Resolve(filepath.FromSlash(location.Path), path))
+		if err != nil {
+			return nil, false
+		}
+		names = append(names, imported.Exports()...)
+	}
+
+	return names, true
+}
+
+// Report each parse error, spanning the token it was found at, followed by any problems found by analysis
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	for _, err := range d.errors {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.toRange(err.Token.Pos, err.Token.End),
+			Severity: SEVERITY_ERROR,
+			Source:   "monkey",
+			Message:  err.Message,
+		})
+	}
+
+	for _, problem := range d.problems {
+		severity := SEVERITY_ERROR
+		if problem.Severity == diagnostic.WARNING {
+			severity = SEVERITY_WARNING
+		}
+
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.toRange(problem.Pos, problem.End),
+			Severity: severity,
+			Code:     problem.Code,
+			Source:   "monkey",
+			Message:  problem.Message,
+		})
+	}
+
+	return diagnostics
+}
+
+// List the names bound by let and const statements, in source order
+func (d *document) symbols() []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		if let, ok := node.(*ast.LetStatement); ok && let.Name != nil {
+			kind := SYMBOL_VARIABLE
+			if let.Constant() {
+				kind = SYMBOL_CONSTANT
+			}
+
+			symbols = append(symbols, DocumentSymbol{
+				Name:           let.Name.Value,
+				Kind:           kind,
+				Range:          d.toRange(let.Pos(), let.End()),
+				SelectionRange: d.toRange(let.Name.Pos(), let.Name.End()),
+			})
+		}
+		return true
+	})
+
+	return symbols
+}
+
+// Encode the document's highlight classes as LSP semantic tokens: five integers per token giving its line
+// relative to the previous token, its start character relative to the previous token on the same line, its
+// length, its type as an index into highlight.Classes, and its modifiers. A token spanning several lines, e.g.,
+// a string containing newlines, is split into one token per line
+func (d *document) semanticTokens() []int {
+	types := make(map[highlight.Class]int)
+	for i, class := range highlight.Classes {
+		types[class] = i
+	}
+
+	data := []int{}
+	previous := Position{}
+
+	for _, span := range highlight.Classify(d.text) {
+		start := span.Pos.Offset
+
+		for start < span.End.Offset {
+			end := span.End.Offset
+			if newline := strings.IndexByte(d.text[start:end], '\n'); newline >= 0 {
+				end = start + newline
+			}
+
+			if end > start {
+				position := d.offsetPosition(start)
+
+				deltaCharacter := position.Character
+				if position.Line == previous.Line {
+					deltaCharacter -= previous.Character
+				}
+
+				data = append(data, position.Line-previous.Line, deltaCharacter, utf16Length(d.text[start:end]), types[span.Class], 0)
+				previous = position
+			}
+
+			start = end + 1
+		}
+	}
+
+	return data
+}
+
+// Convert a byte offset into the text to an LSP position
+func (d *document) offsetPosition(offset int) Position {
+	line := strings.Count(d.text[:offset], "\n")
+	lineStart := strings.LastIndexByte(d.text[:offset], '\n') + 1
+	return Position{Line: line, Character: utf16Length(d.text[lineStart:offset])}
+}
+
+// Find the identifier at or immediately before offset, if any
+func (d *document) identifierAt(offset int) *ast.Identifier {
+	var found *ast.Identifier
+
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		if found != nil || node == nil {
+			return false
+		}
+
+		if identifier, ok := node.(*ast.Identifier); ok && identifier.Pos().Offset <= offset && offset <= identifier.End().Offset {
+			found = identifier
+			return false
+		}
+		return true
+	})
+
+	return found
+}
+
+// Describe the binding an identifier refers to as Markdown, or return false if it isn't bound in the document
+func (d *document) describe(identifier *ast.Identifier) (string, bool) {
+	definition, ok := d.resolution.Definitions[identifier]
+	if !ok {
+		return "", false
+	}
+
+	var declaration, doc string
+	switch binder := d.resolution.Binders[definition].(type) {
+	case *ast.LetStatement:
+		// The doc comment is shown as text below the declaration rather than as part of it
+		withoutDoc := *binder
+		withoutDoc.Doc = nil
+		declaration = strings.TrimSpace(printer.String(&withoutDoc))
+		doc = binder.DocText()
+	case *ast.ForStatement:
+		declaration = fmt.Sprintf("for (%s in %s)", definition.Value, strings.TrimSpace(printer.String(binder.Iterable)))
+	case *ast.TryStatement:
+		declaration = fmt.Sprintf("catch (%s)", definition.Value)
+	default:
+		declaration = definition.Value
+	}
+
+	description := "```monkey\n" + declaration + "\n```"
+	if doc != "" {
+		description += "\n\n" + doc
+	}
+
+	return description, true
+}
+
+// Convert a source position to an LSP position, whose characters are counted in UTF-16 code units
+func (d *document) toPosition(position token.Position) Position {
+	if position.Line == 0 {
+		return Position{}
+	}
+
+	lineStart := position.Offset - (position.Column - 1)
+	return Position{Line: position.Line - 1, Character: utf16Length(d.text[lineStart:position.Offset])}
+}
+
+func (d *document) toRange(start, end token.Position) Range {
+	return Range{Start: d.toPosition(start), End: d.toPosition(end)}
+}
+
+// Convert an LSP position to a byte offset into text, clamping positions beyond the end of a line or the text
+func toOffset(text string, position Position) int {
+	offset := 0
+	for line := 0; line < position.Line; line++ {
+		newline := strings.IndexByte(text[offset:], '\n')
+		if newline < 0 {
+			return len(text)
+		}
+		offset += newline + 1
+	}
+
+	for units := 0; units < position.Character && offset < len(text) && text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		units += runeLength(r)
+		offset += size
+	}
+
+	return offset
+}
+
+func utf16Length(s string) int {
+	length := 0
+	for _, r := range s {
+		length += runeLength(r)
+	}
+	return length
+}
+
+// Count the UTF-16 code units encoding r: two for runes outside the Basic Multilingual Plane, one otherwise
+func runeLength(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
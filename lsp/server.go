@@ -0,0 +1,263 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/highlight"
+	"rowanlovejoy/monkey/parser"
+	"strconv"
+)
+
+// Name the server reports to clients
+const SERVER_NAME = "monkey"
+
+// Serve the Language Server Protocol over in and out, e.g., stdin and stdout, until the client sends 'exit'.
+// Returns an error if the client exits without first requesting shutdown, if the input ends early, or if a
+// message can't be read or written
+func Serve(in io.Reader, out io.Writer) error {
+	s := &server{
+		in:        textproto.NewReader(bufio.NewReader(in)),
+		out:       out,
+		documents: make(map[string]*document),
+	}
+
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			return errors.New("Input ended before exit")
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.respondError(json.RawMessage("null"), PARSE_ERROR, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("Exit before shutdown")
+			}
+			return nil
+		}
+
+		if err := s.handle(&msg); err != nil {
+			return err
+		}
+	}
+}
+
+type server struct {
+	in        *textproto.Reader
+	out       io.Writer
+	documents map[string]*document // Open documents, keyed by URI
+	shutdown  bool                 // Whether the client has requested shutdown, after which only exit is expected
+}
+
+// Read the body of the next message, framed by a Content-Length header
+func (s *server) read() ([]byte, error) {
+	header, err := s.in.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("Invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in.R, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func (s *server) write(value any) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *server) respond(id json.RawMessage, result any) error {
+	return s.write(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *server) respondError(id json.RawMessage, code int, message string) error {
+	return s.write(response{JSONRPC: "2.0", ID: id, Error: &responseError{Code: code, Message: message}})
+}
+
+func (s *server) notify(method string, params any) error {
+	return s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// Handle a request or notification other than exit. Requests are always answered, with an error if they
+// can't be served; notifications that aren't understood are ignored
+func (s *server) handle(msg *message) error {
+	isRequest := msg.ID != nil
+
+	if s.shutdown && isRequest {
+		return s.respondError(msg.ID, INVALID_REQUEST, "Server is shutting down")
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return s.respond(msg.ID, map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       SYNC_INCREMENTAL,
+				"documentSymbolProvider": true,
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"semanticTokensProvider": map[string]any{
+					"legend": map[string]any{"tokenTypes": highlight.Classes, "tokenModifiers": []string{}},
+					"full":   true,
+				},
+			},
+			"serverInfo": map[string]string{"name": SERVER_NAME},
+		})
+	case "shutdown":
+		s.shutdown = true
+		return s.respond(msg.ID, nil)
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return nil
+		}
+		return s.update(params.TextDocument.URI, parser.ParseDocument(params.TextDocument.Text))
+	case "textDocument/didChange":
+		var params didChangeParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return nil
+		}
+		return s.change(params.TextDocument.URI, params.ContentChanges)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return nil
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/documentSymbol":
+		var params textDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.respondError(msg.ID, INVALID_PARAMS, err.Error())
+		}
+		d, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return s.respond(msg.ID, nil)
+		}
+		return s.respond(msg.ID, d.symbols())
+	case "textDocument/semanticTokens/full":
+		var params textDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.respondError(msg.ID, INVALID_PARAMS, err.Error())
+		}
+		d, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return s.respond(msg.ID, nil)
+		}
+		return s.respond(msg.ID, map[string][]int{"data": d.semanticTokens()})
+	case "textDocument/hover", "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.respondError(msg.ID, INVALID_PARAMS, err.Error())
+		}
+		if msg.Method == "textDocument/hover" {
+			return s.respond(msg.ID, s.hover(params))
+		}
+		return s.respond(msg.ID, s.definition(params))
+	}
+
+	if isRequest {
+		return s.respondError(msg.ID, METHOD_NOT_FOUND, fmt.Sprintf("Unsupported method %s", msg.Method))
+	}
+	return nil
+}
+
+// Replace an open document with its newly parsed text and publish its diagnostics
+func (s *server) update(uri string, parsed *parser.Document) error {
+	d := newDocument(uri, parsed)
+	s.documents[uri] = d
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: d.diagnostics()})
+}
+
+// Apply changes to an open document in turn, re-parsing only the statements each edited range affects, then
+// publish its diagnostics. Changes to documents that aren't open are ignored
+func (s *server) change(uri string, changes []textDocumentContentChangeEvent) error {
+	d, ok := s.documents[uri]
+	if !ok || len(changes) == 0 {
+		return nil
+	}
+
+	parsed := d.parsed
+	for _, change := range changes {
+		if change.Range == nil {
+			parsed = parser.ParseDocument(change.Text)
+			continue
+		}
+
+		start := toOffset(parsed.Source, change.Range.Start)
+		end := max(start, toOffset(parsed.Source, change.Range.End))
+		parsed.Apply(parser.Edit{Offset: start, Length: end - start, Text: change.Text})
+	}
+
+	return s.update(uri, parsed)
+}
+
+// Describe the binding of the identifier at a position, or return nil if there's no bound identifier there
+func (s *server) hover(params textDocumentPositionParams) *Hover {
+	d, identifier := s.identifierAt(params)
+	if identifier == nil {
+		return nil
+	}
+
+	description, ok := d.describe(identifier)
+	if !ok {
+		return nil
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: description},
+		Range:    d.toRange(identifier.Pos(), identifier.End()),
+	}
+}
+
+// Locate the binding of the identifier at a position, or return nil if there's no bound identifier there
+func (s *server) definition(params textDocumentPositionParams) *Location {
+	d, identifier := s.identifierAt(params)
+	if identifier == nil {
+		return nil
+	}
+
+	definition, ok := d.resolution.Definitions[identifier]
+	if !ok {
+		return nil
+	}
+
+	return &Location{URI: params.TextDocument.URI, Range: d.toRange(definition.Pos(), definition.End())}
+}
+
+// Find the document and identifier at a position. The identifier is nil if the document isn't open or there's
+// no identifier there
+func (s *server) identifierAt(params textDocumentPositionParams) (*document, *ast.Identifier) {
+	d, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+
+	return d, d.identifierAt(toOffset(d.text, params.Position))
+}
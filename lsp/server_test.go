@@ -0,0 +1,287 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// Frame messages as a client would send them
+func frame(messages ...string) string {
+	var out strings.Builder
+	for _, message := range messages {
+		fmt.Fprintf(&out, "Content-Length: %d\r\n\r\n%s", len(message), message)
+	}
+	return out.String()
+}
+
+// Serve a session and return the messages the server sent, decoded
+func serve(t *testing.T, messages ...string) []map[string]any {
+	var out bytes.Buffer
+	if err := Serve(strings.NewReader(frame(messages...)), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	reader := textproto.NewReader(bufio.NewReader(&out))
+	var sent []map[string]any
+
+	for {
+		header, err := reader.ReadMIMEHeader()
+		if err != nil {
+			return sent
+		}
+
+		var length int
+		fmt.Sscan(header.Get("Content-Length"), &length)
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader.R, body); err != nil {
+			t.Fatalf("Failed to read message body: %v", err)
+		}
+
+		var decoded map[string]any
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			t.Fatalf("Failed to decode %q: %v", body, err)
+		}
+		sent = append(sent, decoded)
+	}
+}
+
+// Encode a value as JSON for comparison
+func encode(t *testing.T, value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("Failed to encode %v: %v", value, err)
+	}
+	return string(encoded)
+}
+
+func open(uri string, text string) string {
+	params, _ := json.Marshal(map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "monkey", "version": 1, "text": text}})
+	return fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":%s}`, params)
+}
+
+func change(uri string, changes ...map[string]any) string {
+	params, _ := json.Marshal(map[string]any{"textDocument": map[string]any{"uri": uri, "version": 2}, "contentChanges": changes})
+	return fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didChange","params":%s}`, params)
+}
+
+// Replace the text between two positions, given as line and character
+func edit(startLine, startCharacter, endLine, endCharacter int, text string) map[string]any {
+	return map[string]any{
+		"range": Range{Start: Position{Line: startLine, Character: startCharacter}, End: Position{Line: endLine, Character: endCharacter}},
+		"text":  text,
+	}
+}
+
+func request(id int, method string, uri string, line, character int) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"%s","params":{"textDocument":{"uri":"%s"},"position":{"line":%d,"character":%d}}}`, id, method, uri, line, character)
+}
+
+const (
+	shutdown = `{"jsonrpc":"2.0","id":99,"method":"shutdown"}`
+	exit     = `{"jsonrpc":"2.0","method":"exit"}`
+)
+
+func TestInitialize(t *testing.T) {
+	sent := serve(t, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`, shutdown, exit)
+
+	if len(sent) != 2 {
+		t.Fatalf("Unexpected message count. Expected 2; got %d: %v", len(sent), sent)
+	}
+
+	expected := `{"definitionProvider":true,"documentSymbolProvider":true,"hoverProvider":true,` +
+		`"semanticTokensProvider":{"full":true,"legend":{"tokenModifiers":[],"tokenTypes":["keyword","operator","string","number","variable","function","property","comment"]}},` +
+		`"textDocumentSync":2}`
+	capabilities := sent[0]["result"].(map[string]any)["capabilities"]
+	if actual := encode(t, capabilities); actual != expected {
+		t.Errorf("Unexpected capabilities. Expected %s; got %s", expected, actual)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	sent := serve(t, open("file:///a.monkey", "let x = 1;\nlet = 2;"), shutdown, exit)
+
+	expected := `{"diagnostics":[` +
+		`{"message":"Unexpected next token. Expected next token to be IDENT; got ASSIGN","range":{"end":{"character":5,"line":1},"start":{"character":4,"line":1}},"severity":1,"source":"monkey"},` +
+		`{"message":"Failed to find prefix parse function for token ASSIGN","range":{"end":{"character":5,"line":1},"start":{"character":4,"line":1}},"severity":1,"source":"monkey"}` +
+		`],"uri":"file:///a.monkey"}`
+
+	if sent[0]["method"] != "textDocument/publishDiagnostics" {
+		t.Fatalf("Unexpected method. Expected %q; got %q", "textDocument/publishDiagnostics", sent[0]["method"])
+	}
+	if actual := encode(t, sent[0]["params"]); actual != expected {
+		t.Errorf("Unexpected diagnostics. Expected %s; got %s", expected, actual)
+	}
+}
+
+func TestAnalysisDiagnostics(t *testing.T) {
+	sent := serve(t, open("file:///a.monkey", "for (i in 1..3) {\n\tlet unused = i;\n}\nmissing;"), shutdown, exit)
+
+	expected := `{"diagnostics":[` +
+		`{"code":"unused","message":"Unused binding unused. Declared with let but never referred to","range":{"end":{"character":11,"line":1},"start":{"character":5,"line":1}},"severity":2,"source":"monkey"},` +
+		`{"message":"Undefined identifier missing. Not bound in any enclosing scope","range":{"end":{"character":7,"line":3},"start":{"character":0,"line":3}},"severity":1,"source":"monkey"}` +
+		`],"uri":"file:///a.monkey"}`
+
+	if actual := encode(t, sent[0]["params"]); actual != expected {
+		t.Errorf("Unexpected diagnostics. Expected %s; got %s", expected, actual)
+	}
+}
+
+func TestAnalysisSkippedWithoutImports(t *testing.T) {
+	// Names bound by imports that can't be loaded are unknown, so nothing can be reported as undefined
+	sent := serve(t, open("untitled:a", `import "lib"; missing;`), shutdown, exit)
+
+	expected := `{"diagnostics":[],"uri":"untitled:a"}`
+	if actual := encode(t, sent[0]["params"]); actual != expected {
+		t.Errorf("Unexpected diagnostics. Expected %s; got %s", expected, actual)
+	}
+}
+
+func TestIncrementalChanges(t *testing.T) {
+	sent := serve(t,
+		open("file:///a.monkey", "let x = 1;\nlet y = x;\nlet z = y;\nz;"),
+		// Rename y to w where it's declared, then insert a line before it, leaving a reference to y undefined
+		change("file:///a.monkey", edit(1, 4, 1, 5, "w"), edit(1, 0, 1, 0, "let v = 0;\nv;\n")),
+		request(1, "textDocument/definition", "file:///a.monkey", 3, 8),
+		// Replace the whole text
+		change("file:///a.monkey", map[string]any{"text": "let a = 1;\na;"}),
+		shutdown, exit)
+
+	expected := `{"diagnostics":[` +
+		`{"message":"Undefined identifier y. Not bound in any enclosing scope","range":{"end":{"character":9,"line":4},"start":{"character":8,"line":4}},"severity":1,"source":"monkey"}` +
+		`],"uri":"file:///a.monkey"}`
+	if actual := encode(t, sent[1]["params"]); actual != expected {
+		t.Errorf("Unexpected diagnostics. Expected %s; got %s", expected, actual)
+	}
+
+	expected = `{"range":{"end":{"character":5,"line":0},"start":{"character":4,"line":0}},"uri":"file:///a.monkey"}`
+	if actual := encode(t, sent[2]["result"]); actual != expected {
+		t.Errorf("Unexpected definition. Expected %s; got %s", expected, actual)
+	}
+
+	expected = `{"diagnostics":[],"uri":"file:///a.monkey"}`
+	if actual := encode(t, sent[3]["params"]); actual != expected {
+		t.Errorf("Unexpected diagnostics. Expected %s; got %s", expected, actual)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	text := "let x = 1;\nfor (i in 1..x) {\n\tconst y = i;\n}"
+	sent := serve(t,
+		open("file:///a.monkey", text),
+		`{"jsonrpc":"2.0","id":1,"method":"textDocument/documentSymbol","params":{"textDocument":{"uri":"file:///a.monkey"}}}`,
+		shutdown, exit,
+	)
+
+	expected := `[` +
+		`{"kind":13,"name":"x","range":{"end":{"character":9,"line":0},"start":{"character":0,"line":0}},"selectionRange":{"end":{"character":5,"line":0},"start":{"character":4,"line":0}}},` +
+		`{"kind":14,"name":"y","range":{"end":{"character":12,"line":2},"start":{"character":1,"line":2}},"selectionRange":{"end":{"character":8,"line":2},"start":{"character":7,"line":2}}}` +
+		`]`
+
+	if actual := encode(t, sent[1]["result"]); actual != expected {
+		t.Errorf("Unexpected symbols. Expected %s; got %s", expected, actual)
+	}
+}
+
+func TestHoverAndDefinition(t *testing.T) {
+	text := "let x = 1 + 2;\nlet y = x;\n{\n\tlet x = \"ü😀\"; x;\n\tx;\n}\nfor (i in 1..y) { i; }\nz;"
+	uri := "file:///a.monkey"
+
+	tests := []struct {
+		line, character int
+		hover           string
+		definition      string
+	}{
+		{1, 8, "```monkey\nlet x = 1 + 2;\n```", `{"end":{"character":5,"line":0},"start":{"character":4,"line":0}}`},
+		{4, 1, "```monkey\nlet x = \"ü😀\";\n```", `{"end":{"character":6,"line":3},"start":{"character":5,"line":3}}`},
+		{4, 2, "```monkey\nlet x = \"ü😀\";\n```", `{"end":{"character":6,"line":3},"start":{"character":5,"line":3}}`},  // Just after the identifier
+		{3, 16, "```monkey\nlet x = \"ü😀\";\n```", `{"end":{"character":6,"line":3},"start":{"character":5,"line":3}}`}, // Counted in UTF-16 code units
+		{6, 18, "```monkey\nfor (i in 1 .. y)\n```", `{"end":{"character":6,"line":6},"start":{"character":5,"line":6}}`},
+		{0, 4, "```monkey\nlet x = 1 + 2;\n```", `{"end":{"character":5,"line":0},"start":{"character":4,"line":0}}`}, // The binding itself
+		{7, 0, "", ""}, // Unbound
+		{1, 1, "", ""}, // Not an identifier
+	}
+
+	messages := []string{open(uri, text)}
+	for i, tt := range tests {
+		messages = append(messages, request(2*i, "textDocument/hover", uri, tt.line, tt.character))
+		messages = append(messages, request(2*i+1, "textDocument/definition", uri, tt.line, tt.character))
+	}
+	messages = append(messages, shutdown, exit)
+
+	// The first message sent is the document's diagnostics
+	sent := serve(t, messages...)[1:]
+
+	for i, tt := range tests {
+		hover := sent[2*i]["result"]
+		definition := sent[2*i+1]["result"]
+
+		if tt.hover == "" {
+			if hover != nil || definition != nil {
+				t.Errorf("tests[%d] - Expected no hover or definition; got %v and %v", i, hover, definition)
+			}
+			continue
+		}
+
+		if actual := hover.(map[string]any)["contents"].(map[string]any)["value"]; actual != tt.hover {
+			t.Errorf("tests[%d] - Unexpected hover. Expected %q; got %q", i, tt.hover, actual)
+		}
+
+		location := definition.(map[string]any)
+		if location["uri"] != uri {
+			t.Errorf("tests[%d] - Unexpected definition URI. Expected %q; got %q", i, uri, location["uri"])
+		}
+		if actual := encode(t, location["range"]); actual != tt.definition {
+			t.Errorf("tests[%d] - Unexpected definition range. Expected %s; got %s", i, tt.definition, actual)
+		}
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	sent := serve(t, `{"jsonrpc":"2.0","id":1,"method":"workspace/symbol","params":{}}`, `{"jsonrpc":"2.0","method":"$/cancelRequest","params":{}}`, shutdown, exit)
+
+	if len(sent) != 2 {
+		t.Fatalf("Unexpected message count. Expected 2; got %d: %v", len(sent), sent)
+	}
+
+	if code := sent[0]["error"].(map[string]any)["code"]; code != float64(METHOD_NOT_FOUND) {
+		t.Errorf("Unexpected error code. Expected %d; got %v", METHOD_NOT_FOUND, code)
+	}
+}
+
+func TestExitBeforeShutdown(t *testing.T) {
+	var out bytes.Buffer
+	if err := Serve(strings.NewReader(frame(exit)), &out); err == nil {
+		t.Errorf("Expected an error exiting before shutdown")
+	}
+}
+
+func TestHoverDocComment(t *testing.T) {
+	uri := "file:///a.monkey"
+	sent := serve(t, open(uri, "/// Number of retries\nconst retries = 3;\nretries;"), request(1, "textDocument/hover", uri, 2, 0), shutdown, exit)
+
+	expected := "```monkey\nconst retries = 3;\n```\n\nNumber of retries\n"
+	if actual := sent[1]["result"].(map[string]any)["contents"].(map[string]any)["value"]; actual != expected {
+		t.Errorf("Unexpected hover. Expected %q; got %q", expected, actual)
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	sent := serve(t,
+		open("file:///a.monkey", "let s = \"a\nb\";\nputs(s);"),
+		`{"jsonrpc":"2.0","id":1,"method":"textDocument/semanticTokens/full","params":{"textDocument":{"uri":"file:///a.monkey"}}}`,
+		shutdown, exit,
+	)
+
+	// The string spans two lines, so is encoded as two tokens
+	expected := `{"data":[0,0,3,0,0,0,4,1,4,0,0,2,1,1,0,0,2,2,2,0,1,0,2,2,0,1,0,4,5,0,0,5,1,4,0]}`
+	if actual := encode(t, sent[1]["result"]); actual != expected {
+		t.Errorf("Unexpected semantic tokens. Expected %s; got %s", expected, actual)
+	}
+}
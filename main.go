@@ -1,19 +1,105 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"os/user"
+	"rowanlovejoy/monkey/diagnostic"
+	"rowanlovejoy/monkey/parser"
 	"rowanlovejoy/monkey/repl"
+	"strings"
 )
 
 func main() {
+	os.Exit(dispatch(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Run the subcommand named in args, the script they name, or, failing that, a program piped to stdin or the REPL.
+// Returns the process exit code
+func dispatch(args []string, stdin *os.File, out io.Writer, errOut io.Writer) int {
+	// Subcommands return early; anything else falls back to the REPL
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			if len(args) != 2 {
+				fmt.Fprintln(errOut, "usage: monkey run file")
+				return 2
+			}
+			return runFile(args[1], out, errOut)
+		case "doc":
+			return documentFile(args[1:], out, errOut)
+		case "fmt":
+			return formatFiles(args[1:], out, errOut)
+		case "check":
+			return checkFiles(args[1:], out, errOut)
+		case "lsp":
+			return serveLanguageServer(stdin, out, errOut)
+		case "parse":
+			return parseFile(args[1:], out, errOut)
+		default:
+			// A script run through its '#!/usr/bin/env monkey' line is passed as the only argument
+			if !strings.HasPrefix(args[0], "-") {
+				return runFile(args[0], out, errOut)
+			}
+		}
+	}
+
+	// Piped input, e.g., echo 'let x = 1; x' | monkey, is run as one program without prompts
+	if !isTerminal(stdin) {
+		return runReader("<stdin>", stdin, out, errOut)
+	}
+
+	return startRepl(args)
+}
+
+// Start an interactive session, returning the process exit code
+func startRepl(args []string) int {
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	noColor := flags.Bool("no-color", false, "disable coloured output")
+	mode := flags.String("mode", string(repl.LEX_MODE), "stage whose output to show: lex, parse, json, or eval")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := repl.CheckMode(repl.Mode(*mode)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+
+	options := []repl.Option{repl.WithInterrupts(interrupts), repl.WithMode(repl.Mode(*mode))}
+	if !*noColor && isTerminal(os.Stdout) {
+		options = append(options, repl.WithColor())
+	}
+
 	fmt.Printf("Hello, %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, options...)
+
+	return 0
+}
+
+// Report whether file is a terminal rather than, e.g., a pipe or regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Print each parse error in source, read from the file at path, with the line it occurs on and a marker under
+// the offending token
+func printParseErrors(w io.Writer, path string, source string, errors []*parser.ParseError) {
+	for _, d := range diagnostic.FromParseErrors(errors) {
+		fmt.Fprint(w, diagnostic.Format(path, source, d))
+	}
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Sources shared by the tests below
+const (
+	VALID_SOURCE       = "let x = 1 + 2;\nx\n"
+	INVALID_SOURCE     = "let = 1;\n"
+	WARNING_SOURCE     = "for (i in 1..3) {\n    let unused = i;\n}\n"
+	DOC_SOURCE         = "/// Adds one\n///   to x\nlet inc = 1;\nlet plain = 2;\n"
+	UNFORMATTED_SOURCE = "let x=1+2;x"
+)
+
+// Write each file into a temporary directory, returning the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		args     []string // $DIR is replaced with the directory holding the files, here and in the output
+		stdin    string
+		exitCode int
+		out      string
+		errOut   string
+	}{
+		// Running scripts
+		{[]string{"run", "$DIR/valid.monkey"}, "", 1, "", "$DIR/valid.monkey: Evaluation isn't available until the evaluator exists\n"},
+		{[]string{"$DIR/valid.monkey"}, "", 1, "", "$DIR/valid.monkey: Evaluation isn't available until the evaluator exists\n"},
+		{[]string{"run", "$DIR/invalid.monkey"}, "", 1, "",
+			"$DIR/invalid.monkey:1:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\nlet = 1;\n    ^\n" +
+				"$DIR/invalid.monkey:1:5: Failed to find prefix parse function for token ASSIGN\nlet = 1;\n    ^\n"},
+		{[]string{"run", "$DIR/missing.monkey"}, "", 1, "", "open $DIR/missing.monkey: no such file or directory\n"},
+		{[]string{"run"}, "", 2, "", "usage: monkey run file\n"},
+		{[]string{"run", "$DIR/valid.monkey", "$DIR/valid.monkey"}, "", 2, "", "usage: monkey run file\n"},
+		{[]string{}, "let y = 2; y", 1, "", "<stdin>: Evaluation isn't available until the evaluator exists\n"},
+		{[]string{}, "let = 2", 1, "",
+			"<stdin>:1:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\nlet = 2\n    ^\n" +
+				"<stdin>:1:5: Failed to find prefix parse function for token ASSIGN\nlet = 2\n    ^\n"},
+
+		// Printing syntax trees
+		{[]string{"parse", "$DIR/valid.monkey"}, "", 0, "let x = (1 + 2);x\n", ""},
+		{[]string{"parse", "$DIR/invalid.monkey"}, "", 1, "",
+			"$DIR/invalid.monkey:1:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\nlet = 1;\n    ^\n" +
+				"$DIR/invalid.monkey:1:5: Failed to find prefix parse function for token ASSIGN\nlet = 1;\n    ^\n"},
+		{[]string{"parse", "--dot", "--json", "$DIR/valid.monkey"}, "", 2, "", "usage: monkey parse [--dot | --json] file\n"},
+		{[]string{"parse"}, "", 2, "", "usage: monkey parse [--dot | --json] file\n"},
+
+		// Formatting
+		{[]string{"fmt", "$DIR/unformatted.monkey"}, "", 0, "let x = 1 + 2;\nx;\n", ""},
+		{[]string{"fmt", "$DIR/invalid.monkey", "$DIR/unformatted.monkey"}, "", 1, "let x = 1 + 2;\nx;\n",
+			"$DIR/invalid.monkey:1:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\nlet = 1;\n    ^\n" +
+				"$DIR/invalid.monkey:1:5: Failed to find prefix parse function for token ASSIGN\nlet = 1;\n    ^\n"},
+		{[]string{"fmt"}, "", 2, "", "usage: monkey fmt [-w] file...\n"},
+
+		// Checking
+		{[]string{"check", "$DIR/valid.monkey"}, "", 0, "", ""},
+		{[]string{"check", "$DIR/warning.monkey"}, "", 0,
+			"$DIR/warning.monkey:2:9: warning: Unused binding unused. Declared with let but never referred to (unused)\n    let unused = i;\n        ^~~~~~\n", ""},
+		{[]string{"check", "--disable", "unused", "$DIR/warning.monkey"}, "", 0, "", ""},
+		{[]string{"check", "$DIR/invalid.monkey", "$DIR/valid.monkey"}, "", 1,
+			"$DIR/invalid.monkey:1:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\nlet = 1;\n    ^\n" +
+				"$DIR/invalid.monkey:1:5: Failed to find prefix parse function for token ASSIGN\nlet = 1;\n    ^\n", ""},
+		{[]string{"check", "--max-errors", "1", "$DIR/invalid.monkey"}, "", 1,
+			"$DIR/invalid.monkey:1:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\nlet = 1;\n    ^\n" +
+				"$DIR/invalid.monkey:1:5: Too many errors. Stopped after 1\nlet = 1;\n    ^\n", ""},
+		{[]string{"check", "$DIR/missing.monkey"}, "", 1, "", "open $DIR/missing.monkey: no such file or directory\n"},
+		{[]string{"check", "$DIR/imports_missing.monkey"}, "", 1, "",
+			"$DIR/imports_missing.monkey:2:1: cannot import \"missing\": open $DIR/missing.monkey: no such file or directory\n" +
+				"import \"missing\";\n^~~~~~~~~~~~~~~~\n"},
+		{[]string{"check", "$DIR/imports_invalid.monkey"}, "", 1,
+			"$DIR/invalid.monkey:1:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\nlet = 1;\n    ^\n" +
+				"$DIR/invalid.monkey:1:5: Failed to find prefix parse function for token ASSIGN\nlet = 1;\n    ^\n", ""},
+		{[]string{"check", "--disable", "nonsense", "$DIR/valid.monkey"}, "", 2, "", "Unknown lint nonsense. Expected one of unused, unreachable, constant-condition\n"},
+		{[]string{"check"}, "", 2, "", "usage: monkey check [--max-errors n] [--disable lints] file...\n"},
+
+		// Documenting
+		{[]string{"doc", "$DIR/doc.monkey"}, "", 0, "let inc\n    Adds one\n      to x\n", ""},
+		{[]string{"doc"}, "", 2, "", "usage: monkey doc file\n"},
+	}
+
+	dir := writeFiles(t, map[string]string{
+		"valid.monkey":       VALID_SOURCE,
+		"invalid.monkey":     INVALID_SOURCE,
+		"warning.monkey":     WARNING_SOURCE,
+		"doc.monkey":         DOC_SOURCE,
+		"unformatted.monkey": UNFORMATTED_SOURCE,
+
+		"imports_missing.monkey": "let a = 1;\nimport \"missing\";\n",
+		"imports_invalid.monkey": "import \"invalid\";\n",
+	})
+
+	for _, test := range tests {
+		args := make([]string, len(test.args))
+		for i, arg := range test.args {
+			args[i] = strings.ReplaceAll(arg, "$DIR", dir)
+		}
+
+		exitCode, out, errOut := dispatchWith(t, args, test.stdin)
+		name := strings.Join(test.args, " ")
+
+		if exitCode != test.exitCode {
+			t.Errorf("Unexpected exit code for %q. Expected %d; got %d", name, test.exitCode, exitCode)
+		}
+		if expected := strings.ReplaceAll(test.out, "$DIR", dir); out != expected {
+			t.Errorf("Unexpected output for %q. Expected %q; got %q", name, expected, out)
+		}
+		if expected := strings.ReplaceAll(test.errOut, "$DIR", dir); errOut != expected {
+			t.Errorf("Unexpected error output for %q. Expected %q; got %q", name, expected, errOut)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"valid.monkey": VALID_SOURCE})
+
+	exitCode, out, errOut := dispatchWith(t, []string{"parse", "--json", filepath.Join(dir, "valid.monkey")}, "")
+	if exitCode != 0 || errOut != "" {
+		t.Fatalf("Unexpected result of parse --json. Expected 0 with no errors; got %d, %q", exitCode, errOut)
+	}
+
+	var program struct {
+		Type       string
+		Statements []struct{ Type string }
+	}
+	if err := json.Unmarshal([]byte(out), &program); err != nil {
+		t.Fatalf("Unexpected invalid JSON: %s", err)
+	}
+
+	if program.Type != "Program" || len(program.Statements) != 2 || program.Statements[0].Type != "LetStatement" {
+		t.Errorf("Unexpected syntax tree. Expected a Program of a LetStatement and one other; got %+v", program)
+	}
+}
+
+func TestFormatWrite(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"unformatted.monkey": UNFORMATTED_SOURCE})
+	path := filepath.Join(dir, "unformatted.monkey")
+
+	if exitCode, out, errOut := dispatchWith(t, []string{"fmt", "-w", path}, ""); exitCode != 0 || out != "" || errOut != "" {
+		t.Fatalf("Unexpected result of fmt -w. Expected 0 with no output; got %d, %q, %q", exitCode, out, errOut)
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "let x = 1 + 2;\nx;\n"; string(source) != expected {
+		t.Errorf("Unexpected formatted file. Expected %q; got %q", expected, source)
+	}
+}
+
+// Dispatch args with stdin read from a file holding the given input, returning the exit code and output
+func dispatchWith(t *testing.T, args []string, input string) (int, string, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdin, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+
+	var out, errOut strings.Builder
+	exitCode := dispatch(args, stdin, &out, &errOut)
+
+	return exitCode, out.String(), errOut.String()
+}
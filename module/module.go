@@ -0,0 +1,178 @@
+package module
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+	"strings"
+)
+
+// Extension assumed for import paths that don't have one, e.g., 'import "lib/strings"' loads lib/strings.monkey
+const EXTENSION = ".monkey"
+
+// A parsed source file along with the modules it imports
+type Module struct {
+	Path    string       // Path of the source file
+	Source  string       // Contents of the file, for showing problems found in it in context
+	Program *ast.Program // Parsed contents of the file
+	Imports []*Module    // Modules imported by the file, in the order their imports appear
+}
+
+// Get the names bound by the let and const statements at the top level of the module, which are visible to
+// modules that import it
+func (m *Module) Exports() []string {
+	var names []string
+
+	for _, statement := range m.Program.Statements {
+		if let, ok := statement.(*ast.LetStatement); ok && let.Name != nil {
+			names = append(names, let.Name.Value)
+		}
+	}
+
+	return names
+}
+
+// Get the names exported by the modules the module imports directly
+func (m *Module) Imported() []string {
+	var names []string
+
+	for _, imported := range m.Imports {
+		names = append(names, imported.Exports()...)
+	}
+
+	return names
+}
+
+// Loads modules and, transitively, the modules they import. Each file is parsed once however many modules
+// import it, and import cycles are reported as errors
+type Loader struct {
+	modules map[string]*Module // Modules loaded so far, by path
+	loading []string           // Paths of the modules currently being loaded, outermost first
+	options []parser.Option    // Options each file is parsed with
+}
+
+// Create a loader that parses each file with options, e.g., parser.WithMaxErrors
+func NewLoader(options ...parser.Option) *Loader {
+	return &Loader{modules: make(map[string]*Module), options: options}
+}
+
+// Load the module at path along with everything it imports
+func (l *Loader) Load(path string) (*Module, error) {
+	return l.load(filepath.Clean(path))
+}
+
+func (l *Loader) load(path string) (*Module, error) {
+	if module, ok := l.modules[path]; ok {
+		return module, nil
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(string(source)), l.options...)
+	program := p.ParseProgram()
+
+	if errors := p.Errors(); len(errors) > 0 {
+		return nil, &ParseErrors{Path: path, Source: string(source), Errors: errors}
+	}
+
+	module := &Module{Path: path, Source: string(source), Program: program}
+
+	l.loading = append(l.loading, path)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	for _, statement := range imports(program) {
+		importPath := Resolve(path, statement.Path.Value)
+
+		for i, loading := range l.loading {
+			if loading == importPath {
+				cycle := strings.Join(append(l.loading[i:], importPath), " -> ")
+				return nil, &ImportError{Module: module, Statement: statement, Message: "import cycle: " + cycle}
+			}
+		}
+
+		imported, err := l.load(importPath)
+		if err != nil {
+			// Errors from deeper imports already locate themselves
+			if _, ok := err.(*os.PathError); ok {
+				message := fmt.Sprintf("cannot import %q: %s", statement.Path.Value, err)
+				return nil, &ImportError{Module: module, Statement: statement, Message: message, Err: err}
+			}
+			return nil, err
+		}
+
+		module.Imports = append(module.Imports, imported)
+	}
+
+	l.modules[path] = module
+
+	return module, nil
+}
+
+// Get the path of the file imported as importPath by the file at importer. Relative paths are resolved
+// against the importer's directory, and paths without an extension are given EXTENSION
+func Resolve(importer string, importPath string) string {
+	path := filepath.FromSlash(importPath)
+
+	if filepath.Ext(path) == "" {
+		path += EXTENSION
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(importer), path)
+	}
+
+	return filepath.Clean(path)
+}
+
+// Find the import statements in a program, wherever they appear
+func imports(program *ast.Program) []*ast.ImportStatement {
+	var statements []*ast.ImportStatement
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		if statement, ok := node.(*ast.ImportStatement); ok {
+			statements = append(statements, statement)
+		}
+		return true
+	})
+
+	return statements
+}
+
+// Errors found while parsing a module
+type ParseErrors struct {
+	Path   string
+	Source string // Contents of the file, for showing the errors in context
+	Errors []*parser.ParseError
+}
+
+// Satisfies error interface. Formats each error on its own line as path:line:column: message
+func (e *ParseErrors) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		lines[i] = e.Path + ":" + err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// An import that couldn't be loaded, e.g., because it's part of a cycle or names a missing file
+type ImportError struct {
+	Module    *Module              // Module containing the import, whose own imports are incomplete
+	Statement *ast.ImportStatement // Import that failed
+	Message   string
+	Err       error // Error reading the imported file, if any
+}
+
+// Satisfies error interface. Formats the error as path:line:column: message, locating the import
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("%s:%s: %s", e.Module.Path, e.Statement.Pos(), e.Message)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,176 @@
+package module
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"rowanlovejoy/monkey/parser"
+	"strings"
+	"testing"
+)
+
+// Write each file, keyed by its path relative to a temporary directory, returning the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.monkey":        `import "lib/strings"; import "lib/lists"; x;`,
+		"lib/strings.monkey": `import "lists"; let upper = 1;`,
+		"lib/lists.monkey":   `let map = 2;`,
+	})
+
+	module, err := NewLoader().Load(filepath.Join(dir, "main.monkey"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(module.Imports) != 2 {
+		t.Fatalf("Unexpected import count. Expected 2; got %d", len(module.Imports))
+	}
+
+	stringsModule, listsModule := module.Imports[0], module.Imports[1]
+
+	if expected := filepath.Join(dir, "lib", "strings.monkey"); stringsModule.Path != expected {
+		t.Errorf("Unexpected module path. Expected %q; got %q", expected, stringsModule.Path)
+	}
+
+	if expected := filepath.Join(dir, "lib", "lists.monkey"); listsModule.Path != expected {
+		t.Errorf("Unexpected module path. Expected %q; got %q", expected, listsModule.Path)
+	}
+
+	// Both importers share the one parsed lists module
+	if len(stringsModule.Imports) != 1 || stringsModule.Imports[0] != listsModule {
+		t.Errorf("Expected lib/strings to import the same lib/lists module as main")
+	}
+
+	if program := listsModule.Program.String(); program != "let map = 2;" {
+		t.Errorf("Unexpected program. Expected %q; got %q", "let map = 2;", program)
+	}
+
+	if imported := strings.Join(module.Imported(), " "); imported != "upper map" {
+		t.Errorf("Unexpected imported names. Expected %q; got %q", "upper map", imported)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		files    map[string]string
+		expected []string // Substrings of the error
+	}{
+		{
+			map[string]string{
+				"main.monkey": `import "a";`,
+				"a.monkey":    `import "b";`,
+				"b.monkey":    `import "a";`,
+			},
+			[]string{"b.monkey:1:1: import cycle:", "a.monkey -> ", "b.monkey -> ", "a.monkey"},
+		},
+		{
+			map[string]string{
+				"main.monkey": `import "main";`,
+			},
+			[]string{"import cycle:"},
+		},
+		{
+			map[string]string{
+				"main.monkey": "let x = 1;\n  import \"missing\";",
+			},
+			[]string{"main.monkey:2:3: cannot import \"missing\":"},
+		},
+		{
+			map[string]string{
+				"main.monkey": `import "a";`,
+				"a.monkey":    `let = 5;`,
+			},
+			[]string{"a.monkey:1:5: Unexpected next token"},
+		},
+	}
+
+	for _, test := range tests {
+		dir := writeFiles(t, test.files)
+
+		_, err := NewLoader().Load(filepath.Join(dir, "main.monkey"))
+		if err == nil {
+			t.Fatalf("Expected an error loading %v; got none", test.files)
+		}
+
+		for _, expected := range test.expected {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("Unexpected error. Expected it to contain %q; got %q", expected, err.Error())
+			}
+		}
+	}
+}
+
+func TestLoadWithParserOptions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.monkey": "let = 1;\nlet = 2;\n"})
+
+	_, err := NewLoader(parser.WithMaxErrors(1)).Load(filepath.Join(dir, "main.monkey"))
+
+	var parseErrors *ParseErrors
+	if !errors.As(err, &parseErrors) {
+		t.Fatalf("Unexpected error. Expected *ParseErrors; got %T: %v", err, err)
+	}
+
+	// The first error, then the notice that parsing stopped
+	if count := len(parseErrors.Errors); count != 2 {
+		t.Errorf("Unexpected error count. Expected 2; got %d: %v", count, parseErrors)
+	}
+	if parseErrors.Source != "let = 1;\nlet = 2;\n" {
+		t.Errorf("Unexpected source. Expected the file's contents; got %q", parseErrors.Source)
+	}
+}
+
+func TestImportError(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.monkey": "let x = 1;\nimport \"missing\";"})
+
+	_, err := NewLoader().Load(filepath.Join(dir, "main.monkey"))
+
+	var importError *ImportError
+	if !errors.As(err, &importError) {
+		t.Fatalf("Unexpected error. Expected *ImportError; got %T: %v", err, err)
+	}
+
+	if path := importError.Statement.Path.Value; path != "missing" {
+		t.Errorf("Unexpected import. Expected %q; got %q", "missing", path)
+	}
+	if importError.Module.Source != "let x = 1;\nimport \"missing\";" {
+		t.Errorf("Unexpected source. Expected the importing file's contents; got %q", importError.Module.Source)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Unexpected error. Expected it to wrap os.ErrNotExist; got %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		importer string
+		path     string
+		expected string
+	}{
+		{"main.monkey", "lib", "lib.monkey"},
+		{"src/main.monkey", "lib/strings", "src/lib/strings.monkey"},
+		{"src/main.monkey", "../shared.mk", "shared.mk"},
+		{"src/main.monkey", "/abs/lib", "/abs/lib.monkey"},
+	}
+
+	for _, test := range tests {
+		if actual := Resolve(filepath.FromSlash(test.importer), test.path); actual != filepath.FromSlash(test.expected) {
+			t.Errorf("Unexpected path for %q imported from %q. Expected %q; got %q", test.path, test.importer, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package optimizer
+
+import (
+	"math"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/token"
+	"strconv"
+)
+
+// Replace integer arithmetic whose operands are all literals with the literal result, e.g.,
+// '2 * 3 + 4' becomes '10'. Folding happens bottom-up, so nested constant expressions collapse fully.
+// Bitwise operators apply to the integers' two's complement representation. Division by zero, shifts by a
+// negative amount, and arithmetic that would overflow are left in place so they fail at runtime as they
+// would unoptimised. The program is folded in place, replacing nodes within it, and returned; reparse the source
+// first to keep the unfolded tree
+func Fold(program *ast.Program) *ast.Program {
+	return ast.Rewrite(program, foldNode).(*ast.Program)
+}
+
+func foldNode(node ast.Node) ast.Node {
+	switch node := node.(type) {
+	case *ast.PrefixExpression:
+		right, ok := node.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return node
+		}
+
+		switch node.Operator {
+		case "-":
+			if right.Value == math.MinInt64 {
+				return node
+			}
+			return newIntegerLiteral(node, -right.Value)
+		case "~":
+			return newIntegerLiteral(node, ^right.Value)
+		}
+	case *ast.InfixExpression:
+		left, ok := node.Left.(*ast.IntegerLiteral)
+		if !ok {
+			return node
+		}
+		right, ok := node.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return node
+		}
+
+		switch node.Operator {
+		case "+":
+			sum := left.Value + right.Value
+			if sumOverflowed(left.Value, right.Value, sum) {
+				return node
+			}
+			return newIntegerLiteral(node, sum)
+		case "-":
+			difference := left.Value - right.Value
+			if differenceOverflowed(left.Value, right.Value, difference) {
+				return node
+			}
+			return newIntegerLiteral(node, difference)
+		case "*":
+			product := left.Value * right.Value
+			if left.Value != 0 && (product/left.Value != right.Value || left.Value == -1 && right.Value == math.MinInt64) {
+				return node
+			}
+			return newIntegerLiteral(node, product)
+		case "/":
+			if right.Value == 0 || left.Value == math.MinInt64 && right.Value == -1 {
+				return node
+			}
+			return newIntegerLiteral(node, left.Value/right.Value)
+		case "&":
+			return newIntegerLiteral(node, left.Value&right.Value)
+		case "|":
+			return newIntegerLiteral(node, left.Value|right.Value)
+		case "^":
+			return newIntegerLiteral(node, left.Value^right.Value)
+		case "<<":
+			if right.Value < 0 {
+				return node
+			}
+			return newIntegerLiteral(node, left.Value<<right.Value)
+		case ">>":
+			if right.Value < 0 {
+				return node
+			}
+			return newIntegerLiteral(node, left.Value>>right.Value)
+		}
+	}
+
+	return node
+}
+
+// Report whether sum, the wrapped result of a + b, overflowed. Adding numbers of the same sign overflows
+// exactly when the result's sign differs from theirs
+func sumOverflowed(a, b, sum int64) bool {
+	return (a >= 0) == (b >= 0) && (sum >= 0) != (a >= 0)
+}
+
+// Report whether difference, the wrapped result of a - b, overflowed. Subtracting numbers of differing signs
+// overflows exactly when the result's sign differs from a's
+func differenceOverflowed(a, b, difference int64) bool {
+	return (a >= 0) != (b >= 0) && (difference >= 0) != (a >= 0)
+}
+
+// Create a literal for a folded value, spanning the source of the expression it replaces
+func newIntegerLiteral(replaced ast.Node, value int64) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{
+		Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(value, 10), Pos: replaced.Pos(), End: replaced.End()},
+		Value: value,
+	}
+}
@@ -0,0 +1,59 @@
+package optimizer
+
+import (
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 3 + 4", "10"},
+		{"2 + 3 * 4", "14"},
+		{"(2 + 3) * 4", "20"},
+		{"10 / 3", "3"},
+		{"-5 + 2", "(-3)"},
+		{"-(5 + 2)", "(-7)"},
+		{"let x = 1 + 2 * 3;", "let x = 7;"},
+		{"return 8 - 2 - 1;", "return 5;"},
+		{"x + 2 * 3", "(x + 6)"},
+		{"1 + 2 + x", "(3 + x)"},
+		{"x + 1 + 2", "((x + 1) + 2)"},
+		{"5 / 0", "(5 / 0)"},
+		{"5 / (3 - 3)", "(5 / 0)"},
+		{"1 < 2", "(1 < 2)"},
+		{"!5", "(!5)"},
+		{"6 & 3 | 8", "10"},
+		{"6 ^ 3", "5"},
+		{"~5", "(-6)"},
+		{"1 << 4 >> 2", "4"},
+		{"-16 >> 2", "(-4)"},
+		{"1 << -1", "(1 << (-1))"},
+		{"9223372036854775806 + 1", "9223372036854775807"},
+		{"9223372036854775807 + 1", "(9223372036854775807 + 1)"},
+		{"-9223372036854775807 - 1", "(-9223372036854775808)"},
+		{"-9223372036854775807 - 2", "((-9223372036854775807) - 2)"},
+		{"-(-9223372036854775807 - 1)", "(-(-9223372036854775808))"},
+		{"1 - (-9223372036854775807 - 1)", "(1 - (-9223372036854775808))"},
+		{"-1 - (-9223372036854775807 - 1)", "9223372036854775807"},
+		{"3037000500 * 3037000500", "(3037000500 * 3037000500)"},
+		{"3037000499 * 3037000499", "9223372030926249001"},
+		{"-1 * (-9223372036854775807 - 1)", "((-1) * (-9223372036854775808))"},
+		{"(-9223372036854775807 - 1) / -1", "((-9223372036854775808) / (-1))"},
+	}
+
+	for _, test := range tests {
+		p := parser.New(lexer.New(test.input))
+		program := p.ParseProgram()
+		if errors := p.Errors(); len(errors) > 0 {
+			t.Fatalf("Parser errors for %q: %v", test.input, errors)
+		}
+
+		if actual := Fold(program).String(); actual != test.expected {
+			t.Errorf("Unexpected folded program for %q. Expected %q; got %q", test.input, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+)
+
+// Parse the file named in args and print its syntax tree, fully parenthesised or, with --dot, as a GraphViz
+// DOT graph or, with --json, as JSON. Returns the process exit code
+func parseFile(args []string, out io.Writer, errOut io.Writer) int {
+	flags := flag.NewFlagSet("parse", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	dot := flags.Bool("dot", false, "print the syntax tree as a GraphViz DOT graph")
+	asJSON := flags.Bool("json", false, "print the syntax tree as JSON")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 || *dot && *asJSON {
+		fmt.Fprintln(errOut, "usage: monkey parse [--dot | --json] file")
+		return 2
+	}
+
+	path := flags.Arg(0)
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(errOut, "%s\n", err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+
+	if errors := p.Errors(); len(errors) > 0 {
+		printParseErrors(errOut, path, string(source), errors)
+		return 1
+	}
+
+	if *dot {
+		if err := ast.WriteDot(out, program); err != nil {
+			fmt.Fprintf(errOut, "%s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *asJSON {
+		if err := ast.WriteJSON(out, program); err != nil {
+			fmt.Fprintf(errOut, "%s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintln(out, program.String())
+
+	return 0
+}
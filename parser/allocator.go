@@ -0,0 +1,20 @@
+package parser
+
+// Number of nodes of a type allocated at once by a nodeAllocator
+const NODE_CHUNK_SIZE = 64
+
+// Hands out nodes of one type from preallocated chunks, trading an allocation per node for one per chunk.
+// Used for the most frequently parsed node types. A chunk stays in memory while any of its nodes is reachable
+type nodeAllocator[T any] struct {
+	chunk []T // Unused nodes remaining in the current chunk
+}
+
+func (a *nodeAllocator[T]) new() *T {
+	if len(a.chunk) == 0 {
+		a.chunk = make([]T, NODE_CHUNK_SIZE)
+	}
+
+	node := &a.chunk[0]
+	a.chunk = a.chunk[1:]
+	return node
+}
@@ -0,0 +1,19 @@
+package parser
+
+import (
+	"fmt"
+	"rowanlovejoy/monkey/token"
+)
+
+// An error encountered while parsing, locating the problem in the source
+type ParseError struct {
+	Pos      token.Position    // Where in the source the error occurred
+	Token    token.Token       // Offending token
+	Expected []token.TokenType // Token types that would have been valid, if known
+	Message  string
+}
+
+// Satisfies error interface. Formats the error as line:column: message
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
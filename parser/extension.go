@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/token"
+)
+
+// Register fn to parse expressions beginning with tokens of the given type, replacing any existing
+// function for that type. fn is called with the operator as the current token and must leave the
+// parser on the last token of the expression it parsed, returning nil on failure
+func (p *Parser) RegisterPrefix(tokenType token.TokenType, fn PrefixParseFn) {
+	p.registerPrefix(tokenType, fn)
+}
+
+// Register fn to parse expressions in which tokens of the given type follow a left operand, replacing
+// any existing function for that type. The token type also needs a precedence above LOWEST, set
+// with SetPrecedence unless it already has one, or the parser will never treat it as an infix operator
+func (p *Parser) RegisterInfix(tokenType token.TokenType, fn InfixParseFn) {
+	p.registerInfix(tokenType, fn)
+}
+
+// Set the precedence level of an infix operator token for this parser only, e.g., SUM or
+// a value between two of the levels. Other parsers continue to use the default precedences
+func (p *Parser) SetPrecedence(tokenType token.TokenType, precedence int) {
+	if p.precedences == nil {
+		p.precedences = make(map[token.TokenType]int)
+	}
+
+	p.precedences[tokenType] = precedence
+}
+
+// Get the token under examination, for use by registered parse functions
+func (p *Parser) CurrToken() token.Token {
+	return p.currToken
+}
+
+// Get the token following the current token, for use by registered parse functions
+func (p *Parser) PeekToken() token.Token {
+	return p.peekToken
+}
+
+// Advance to the next token, for use by registered parse functions
+func (p *Parser) NextToken() {
+	p.nextToken()
+}
+
+// Advance to the next token if it has the given type, otherwise record an error and report false
+func (p *Parser) ExpectPeek(t token.TokenType) bool {
+	return p.expectPeek(t)
+}
+
+// Parse an operand beginning at the current token, binding only operators with a higher precedence.
+// For use by registered parse functions, e.g., to parse the right operand of an infix operator
+func (p *Parser) ParseOperand(precedence int) ast.Expression {
+	return p.parseExpression(precedence)
+}
+
+// Record an error at the current token, for use by registered parse functions
+func (p *Parser) ReportError(message string) {
+	p.addError(p.currToken, nil, message)
+}
@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"reflect"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/token"
+	"strings"
+)
+
+// A change to a source: the Length bytes at Offset are replaced with Text
+type Edit struct {
+	Offset int
+	Length int
+	Text   string
+}
+
+// A source parsed so that edits can be applied to it incrementally, e.g., as the user of an editor types. An edit
+// re-lexes and re-parses only the top-level statements it may affect: the nodes of statements before it are
+// reused, as are those of the statements after it once parsing reaches one that began on a line after the edit
+// ended, which are moved to their new positions. The result is the same as parsing the edited source afresh with
+// a default Parser
+type Document struct {
+	Source  string
+	Program *ast.Program
+	Errors  []*ParseError
+
+	units []unit // The result of parsing each top-level statement, in source order
+}
+
+// The result of one iteration of ParseProgram: parsing a top-level statement, then advancing past it
+type unit struct {
+	statement ast.Statement // nil if the statement was empty or failed to parse
+	errors    []*ParseError // Errors recorded while parsing the statement and advancing past it
+
+	before token.Position // End of the previous unit's last token, or the start of the source
+	first  token.Position // Start of the unit's first token, or of the doc comment preceding it
+	start  token.Position // Start of the unit's first token
+	next   token.Position // End of the next unit's first token, the last token read before the unit was complete
+
+	interpolated bool // Whether the unit began within a string interpolation, where lexing can't restart
+}
+
+// Parse a source, ready to apply edits to it
+func ParseDocument(source string) *Document {
+	d := &Document{Source: source}
+	units, _ := parseUnits(source, token.Position{Line: 1, Column: 1}, nil)
+	d.update(units)
+	return d
+}
+
+// Apply an edit to the document's source, updating its program and errors. The nodes of the previous program
+// may be reused, moved to their new positions, so it should no longer be used. The edit must lie within the
+// source
+func (d *Document) Apply(edit Edit) {
+	oldEnd := edit.Offset + edit.Length
+	source := d.Source[:edit.Offset] + edit.Text + d.Source[oldEnd:]
+	offsets := len(edit.Text) - edit.Length
+	lines := strings.Count(edit.Text, "\n") - strings.Count(d.Source[edit.Offset:oldEnd], "\n")
+	endLine := strings.Count(d.Source[:oldEnd], "\n") + 1
+
+	// A unit is reused if every char lexed to find its tokens comes before the edit. The lexer looks as far as
+	// the char after the end of a token, e.g., to tell a / from the /// of a doc comment. Lexing must then
+	// restart outside any string interpolation
+	reused := 0
+	for reused < len(d.units)-1 && d.units[reused].next.Offset+1 < edit.Offset {
+		reused += 1
+	}
+	for reused > 0 && d.units[reused].interpolated {
+		reused -= 1
+	}
+
+	start := token.Position{Line: 1, Column: 1}
+	if reused < len(d.units) {
+		start = d.units[reused].before
+	}
+
+	// Parsing stops at a unit the edit can't have affected: one beginning at the same place in the text after the
+	// edit, entirely on lines after it, so that only its lines and offsets differ
+	old := d.units
+	resumed := reused
+	parsed, stopped := parseUnits(source, start, func(u unit) bool {
+		for resumed < len(old) && (old[resumed].before.Offset < oldEnd || old[resumed].start.Offset+offsets < u.start.Offset) {
+			resumed += 1
+		}
+		if resumed == len(old) {
+			return false
+		}
+
+		o := old[resumed]
+		return o.before.Offset+offsets == u.before.Offset && o.start.Offset+offsets == u.start.Offset &&
+			o.first.Line > endLine && !o.interpolated && !u.interpolated
+	})
+
+	units := append(append([]unit{}, old[:reused]...), parsed...)
+	if stopped {
+		for _, u := range old[resumed:] {
+			units = append(units, u.shift(lines, offsets))
+		}
+	}
+
+	d.Source = source
+	d.update(units)
+}
+
+// Replace the document's units, rebuilding its program and errors from them
+func (d *Document) update(units []unit) {
+	d.units = units
+	d.Program = &ast.Program{Statements: []ast.Statement{}}
+	d.Errors = []*ParseError{}
+
+	for _, u := range units {
+		if u.statement != nil {
+			d.Program.Statements = append(d.Program.Statements, u.statement)
+		}
+		d.Errors = append(d.Errors, u.errors...)
+	}
+}
+
+// Parse the top-level statements of source from start, the end of a unit or the start of the source, to its end.
+// Before parsing each unit after the first, stop is called with what's known of it so far; parsing ends early if
+// it reports true, leaving the unit out, and parseUnits reports true too
+func parseUnits(source string, start token.Position, stop func(unit) bool) ([]unit, bool) {
+	p := New(lexer.New(source[start.Offset:], lexer.WithStart(start)))
+
+	var units []unit
+	before := start
+	recorded := 0
+
+	// Errors found reading the first token, e.g., that a doc comment precedes it but it isn't a let statement,
+	// belong to the previous unit, which has them already
+	if start.Offset > 0 {
+		recorded = len(p.errors)
+	}
+
+	for !p.currTokenIs(token.EOF) {
+		u := unit{
+			before:       before,
+			first:        p.currToken.Pos,
+			start:        p.currToken.Pos,
+			interpolated: p.interpolations-interpolationChange(p.currToken)-interpolationChange(p.peekToken) > 0,
+		}
+		if len(p.currDoc) > 0 {
+			u.first = p.currDoc[0].Pos
+		}
+
+		if len(units) > 0 && stop != nil && stop(u) {
+			return units, true
+		}
+
+		if statement, ok := p.parseStatement(); ok {
+			u.statement = statement
+		}
+
+		before = p.currToken.End
+		p.nextToken()
+
+		u.next = p.currToken.End
+		u.errors = p.errors[recorded:len(p.errors):len(p.errors)]
+		recorded = len(p.errors)
+		units = append(units, u)
+	}
+
+	// Errors found before the first statement, e.g., a doc comment followed by nothing, are kept in a unit of
+	// their own
+	if recorded < len(p.errors) {
+		units = append(units, unit{before: before, first: p.currToken.Pos, start: p.currToken.Pos, next: p.currToken.End, errors: p.errors[recorded:]})
+	}
+
+	return units, false
+}
+
+// Move a unit from after an edit down by lines and along by offset bytes, along with its statement and errors
+func (u unit) shift(lines, offset int) unit {
+	for _, position := range []*token.Position{&u.before, &u.first, &u.start, &u.next} {
+		shiftPosition(position, lines, offset)
+	}
+
+	ast.Inspect(u.statement, func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+		shiftTokens(reflect.Indirect(reflect.ValueOf(node)), lines, offset)
+		return true
+	})
+
+	for _, err := range u.errors {
+		shiftPosition(&err.Pos, lines, offset)
+		shiftToken(&err.Token, lines, offset)
+	}
+
+	return u
+}
+
+var (
+	tokenType  = reflect.TypeOf(token.Token{})
+	tokensType = reflect.TypeOf([]token.Token{})
+)
+
+// Shift the tokens held directly in the fields of a node's struct, e.g., its Token and any Doc, RBrace, or RParen
+func shiftTokens(node reflect.Value, lines, offset int) {
+	if node.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < node.NumField(); i++ {
+		field := node.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+
+		switch field.Type() {
+		case tokenType:
+			shiftToken(field.Addr().Interface().(*token.Token), lines, offset)
+		case tokensType:
+			for j := 0; j < field.Len(); j++ {
+				shiftToken(field.Index(j).Addr().Interface().(*token.Token), lines, offset)
+			}
+		}
+	}
+}
+
+func shiftToken(tok *token.Token, lines, offset int) {
+	shiftPosition(&tok.Pos, lines, offset)
+	shiftPosition(&tok.End, lines, offset)
+}
+
+// Shift a position, leaving the zero position of an absent token, e.g., the RBrace of a switch case's body, as is
+func shiftPosition(position *token.Position, lines, offset int) {
+	if position.Line == 0 {
+		return
+	}
+
+	position.Line += lines
+	position.Offset += offset
+}
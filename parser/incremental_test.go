@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"fmt"
+	"math/rand"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/lexer"
+	"strings"
+	"testing"
+)
+
+const INCREMENTAL_SOURCE = `/// The answer
+let answer = 42;
+let greeting = "Hello, ${name}!";
+
+for (x in 1..10) {
+    total += x * 2;
+}
+
+const limit = switch answer {
+    case 1: "one"
+    default: "many"
+};
+
+try {
+    risky(answer)
+} catch (e) {
+    print(e)
+}
+
+person.name |> print
+`
+
+func TestDocumentApply(t *testing.T) {
+	tests := []struct {
+		source string
+		edits  []Edit
+	}{
+		// Typing an identifier a character at a time
+		{"let a = 1;\nlet b = 2;\n", []Edit{{8, 0, "x"}, {9, 0, "y"}, {10, 1, ""}}},
+		// Extending the token the edit begins after
+		{"let a = 1;\nlet b = ab;\nlet c = 3;\n", []Edit{{21, 0, "c"}}},
+		// Inserting and removing lines
+		{INCREMENTAL_SOURCE, []Edit{{0, 0, "let first = 0;\n"}, {0, 15, ""}, {31, 0, "\n\n\n"}}},
+		// Breaking a statement so that it swallows the next, then mending it
+		{INCREMENTAL_SOURCE, []Edit{{60, 0, "("}, {60, 1, ""}}},
+		// Opening and closing a string, changing how everything after it is lexed
+		{INCREMENTAL_SOURCE, []Edit{{28, 0, `"`}, {28, 1, ""}}},
+		// Within an interpolation
+		{"let a = \"${\nb\n}\";\nlet c = 1;\n", []Edit{{12, 1, "bb"}}},
+		// An unclosed interpolation spanning statements
+		{"let a = \"${ b;\nlet c = 1;\nlet d = 2;\n", []Edit{{27, 1, "3"}}},
+		// Doc comments that precede nothing, then something
+		{"let a = 1;\n/// Doc\n", []Edit{{19, 0, "let b = 2;\n"}, {11, 8, ""}}},
+		// Edits at the start and end of the source
+		{"let a = 1;", []Edit{{0, 0, "x;"}, {12, 0, " y"}, {0, 14, ""}, {0, 0, "z"}}},
+	}
+
+	for i, tt := range tests {
+		d := ParseDocument(tt.source)
+
+		for j, edit := range tt.edits {
+			d.Apply(edit)
+			checkDocument(t, fmt.Sprintf("tests[%d] edits[%d]", i, j), d)
+		}
+	}
+}
+
+// Apply random edits to a source, comparing the document after each with a fresh parse of its source
+func TestDocumentApplyRandomEdits(t *testing.T) {
+	snippets := []string{"", "\n", " ", ";", "x", "1", "(", ")", "{", "}", "\"", "${", "let y = ", "/// Doc\n", "+ 2", "\nlet z = 3;\n"}
+	random := rand.New(rand.NewSource(1))
+	d := ParseDocument(INCREMENTAL_SOURCE)
+
+	for i := 0; i < 2000; i++ {
+		offset := random.Intn(len(d.Source) + 1)
+		length := random.Intn(min(len(d.Source)-offset, 8) + 1)
+		edit := Edit{Offset: offset, Length: length, Text: snippets[random.Intn(len(snippets))]}
+
+		// Keep the source from growing or shrinking without bound
+		if len(d.Source) > 2*len(INCREMENTAL_SOURCE) || len(d.Source) < len(INCREMENTAL_SOURCE)/2 {
+			edit = Edit{Offset: 0, Length: len(d.Source), Text: INCREMENTAL_SOURCE}
+		}
+
+		d.Apply(edit)
+		if !checkDocument(t, fmt.Sprintf("edits[%d] %+v", i, edit), d) {
+			return
+		}
+	}
+}
+
+func TestDocumentReusesStatements(t *testing.T) {
+	d := ParseDocument(INCREMENTAL_SOURCE)
+	before := d.Program.Statements
+
+	// Change the value of greeting, on the third line
+	offset := strings.Index(INCREMENTAL_SOURCE, "Hello")
+	d.Apply(Edit{Offset: offset, Length: len("Hello"), Text: "Hi"})
+	checkDocument(t, "edit", d)
+
+	if len(d.Program.Statements) != len(before) {
+		t.Fatalf("Unexpected statement count. Expected %d; got %d", len(before), len(d.Program.Statements))
+	}
+
+	for i, statement := range d.Program.Statements {
+		reused := statement == before[i]
+		if expected := i != 1; reused != expected {
+			t.Errorf("Unexpected reuse of statements[%d]. Expected %t; got %t", i, expected, reused)
+		}
+	}
+
+	if line := d.Program.Statements[3].Pos().Line; line != 9 {
+		t.Errorf("Unexpected line of moved statement. Expected 9; got %d", line)
+	}
+}
+
+// Check that a document's program and errors match those from parsing its source afresh, positions included
+func checkDocument(t *testing.T, name string, d *Document) bool {
+	t.Helper()
+
+	p := New(lexer.New(d.Source))
+	program := p.ParseProgram()
+
+	if expected, actual := programJSON(t, program), programJSON(t, d.Program); expected != actual {
+		t.Errorf("%s: unexpected program for %q.\nExpected %s\ngot      %s", name, d.Source, expected, actual)
+		return false
+	}
+
+	if expected, actual := describeErrors(p.Errors()), describeErrors(d.Errors); expected != actual {
+		t.Errorf("%s: unexpected errors for %q.\nExpected %s\ngot      %s", name, d.Source, expected, actual)
+		return false
+	}
+
+	return true
+}
+
+func programJSON(t *testing.T, program *ast.Program) string {
+	t.Helper()
+
+	var out strings.Builder
+	if err := ast.WriteJSON(&out, program); err != nil {
+		t.Fatalf("Unexpected error writing JSON: %s", err)
+	}
+
+	// Doc comments aren't part of the JSON
+	ast.Inspect(program, func(node ast.Node) bool {
+		if let, ok := node.(*ast.LetStatement); ok {
+			for _, doc := range let.Doc {
+				fmt.Fprintf(&out, " %v", doc.Pos)
+			}
+		}
+		return true
+	})
+
+	return out.String()
+}
+
+func describeErrors(errors []*ParseError) string {
+	var out strings.Builder
+	for _, err := range errors {
+		fmt.Fprintf(&out, "[%v %v-%v %s] ", err.Pos, err.Token.Pos, err.Token.End, err.Message)
+	}
+	return out.String()
+}
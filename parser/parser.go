@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"rowanlovejoy/monkey/ast"
 	"rowanlovejoy/monkey/lexer"
 	"rowanlovejoy/monkey/token"
@@ -10,55 +11,138 @@ import (
 
 const (
 	LOWEST      = iota
-	EQUALS      // =
+	ASSIGN      // x = y
+	TERNARY     // x ? y : z
+	PIPE        // x |> f
+	EQUALS      // ==
+	MEMBERSHIP  // x in y
 	LESSGREATER // < or >
+	RANGE       // x..y
+	BITOR       // x | y
+	BITXOR      // x ^ y
+	BITAND      // x & y
+	SHIFT       // x << y or x >> y
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -x or !x
 	CALL        // myFunction(x)
+	INDEX       // person.name
 )
 
+// Default limit on how deeply expressions and blocks may nest before parsing is abandoned
+const DEFAULT_MAX_DEPTH = 1000
+
 type (
-	prefixParseFn func() ast.Expression
-	infixParseFn  func(ast.Expression) ast.Expression
+	PrefixParseFn func() ast.Expression               // Parses an expression starting at the current token
+	InfixParseFn  func(ast.Expression) ast.Expression // Parses an expression whose left operand has been parsed
 )
 
 // Table of precedence levels for each token type when parsing expression
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOTEQ:    EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
+	token.ASSIGN:         ASSIGN,
+	token.PLUSASSIGN:     ASSIGN,
+	token.MINUSASSIGN:    ASSIGN,
+	token.ASTERISKASSIGN: ASSIGN,
+	token.SLASHASSIGN:    ASSIGN,
+	token.QUESTION:       TERNARY,
+	token.PIPE:           PIPE,
+	token.EQ:             EQUALS,
+	token.NOTEQ:          EQUALS,
+	token.IN:             MEMBERSHIP,
+	token.LT:             LESSGREATER,
+	token.GT:             LESSGREATER,
+	token.DOTDOT:         RANGE,
+	token.BAR:            BITOR,
+	token.CARET:          BITXOR,
+	token.AMPERSAND:      BITAND,
+	token.LTLT:           SHIFT,
+	token.GTGT:           SHIFT,
+	token.PLUS:           SUM,
+	token.MINUS:          SUM,
+	token.SLASH:          PRODUCT,
+	token.ASTERISK:       PRODUCT,
+	token.LPAREN:         CALL,
+	token.DOT:            INDEX,
+	token.LBRACKET:       INDEX,
 }
 
+// Parses a program from the tokens of a Lexer. A Parser holds all of its state, including its trace output and
+// any parse functions and precedences set on it, so separate Parsers may be used concurrently; a single Parser
+// may not
 type Parser struct {
 	lexer *lexer.Lexer
 	// Analogous to Lexer's position and readPosition but store tokens instead of chars
-	errors []string // Error messages generated while parsing
+	errors []*ParseError // Errors generated while parsing
 
 	currToken token.Token // Current token under examination
 	peekToken token.Token // Next token in the sequence, can give context to current token when parsing
 
-	prefixParseFns map[token.TokenType]prefixParseFn
-	infixParseFns  map[token.TokenType]infixParseFn
+	currDoc []token.Token // Doc comment lines immediately preceding currToken
+	peekDoc []token.Token // Doc comment lines immediately preceding peekToken
+
+	interpolations int // String interpolations opened but not yet closed by the tokens read so far
+
+	grouped ast.Expression // Expression most recently parsed between parentheses, e.g., a deliberate (a < b) < c
+
+	loops int // Number of loop bodies enclosing the current token, outside of which break and continue aren't allowed
+
+	traceOut   io.Writer // Destination for parse function enter/exit events; nil disables tracing
+	traceLevel int       // Current depth of nested parse functions, used to indent trace events
+
+	depth     int  // Current nesting depth of expressions and blocks
+	maxDepth  int  // Nesting depth beyond which parsing is abandoned; zero or less disables the limit
+	maxErrors int  // Number of errors after which parsing is abandoned; zero or less disables the limit
+	abandoned bool // Whether parsing was abandoned, after which no further errors are recorded
+
+	prefixParseFns map[token.TokenType]PrefixParseFn
+	infixParseFns  map[token.TokenType]InfixParseFn
+	precedences    map[token.TokenType]int // Precedences set on this parser, overriding the defaults; nil if none
+
+	// Allocators for the most common nodes, reducing per-node allocations when parsing large programs
+	identifiers          nodeAllocator[ast.Identifier]
+	integerLiterals      nodeAllocator[ast.IntegerLiteral]
+	infixExpressions     nodeAllocator[ast.InfixExpression]
+	expressionStatements nodeAllocator[ast.ExpressionStatement]
+}
+
+// Configures optional Parser behaviour when passed to New
+type Option func(*Parser)
+
+// Limit how deeply expressions and blocks may nest, reporting an error rather than exhausting the
+// stack on input like thousands of ( characters. A limit of zero or less disables the check
+func WithMaxDepth(depth int) Option {
+	return func(p *Parser) {
+		p.maxDepth = depth
+	}
+}
+
+// Stop parsing after max errors, recording a final error saying so, rather than reporting every error in a
+// large file. A limit of zero or less, the default, disables the check
+func WithMaxErrors(max int) Option {
+	return func(p *Parser) {
+		p.maxErrors = max
+	}
 }
 
-func New(l *lexer.Lexer) *Parser {
+func New(l *lexer.Lexer, options ...Option) *Parser {
 	p := &Parser{
 		lexer:          l,
-		errors:         []string{},
-		prefixParseFns: make(map[token.TokenType]prefixParseFn),
-		infixParseFns:  make(map[token.TokenType]infixParseFn),
+		errors:         []*ParseError{},
+		maxDepth:       DEFAULT_MAX_DEPTH,
+		prefixParseFns: make(map[token.TokenType]PrefixParseFn),
+		infixParseFns:  make(map[token.TokenType]InfixParseFn),
 	}
 
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRINGHEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.SWITCH, p.parseSwitchExpression)
 
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
@@ -68,6 +152,27 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOTEQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.IN, p.parseInfixExpression)
+	p.registerInfix(token.DOTDOT, p.parseInfixExpression)
+	p.registerInfix(token.BAR, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.LTLT, p.parseInfixExpression)
+	p.registerInfix(token.GTGT, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUSASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUSASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISKASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASHASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.QUESTION, p.parseTernaryExpression)
+	p.registerInfix(token.DOT, p.parseDotExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
+
+	for _, option := range options {
+		option(p)
+	}
 
 	// Read two tokens so that currToken and peekToken are both initialised
 	p.nextToken() // Initialises peekToken
@@ -76,48 +181,144 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
-func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
+func (p *Parser) registerPrefix(tokenType token.TokenType, fn PrefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
 }
 
-func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
+func (p *Parser) registerInfix(tokenType token.TokenType, fn InfixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
-func (p *Parser) peekPrecedence() int {
-	if precedence, ok := precedences[p.peekToken.Type]; ok {
+// Get the precedence level of an infix operator token, or LOWEST if it isn't one
+func Precedence(tokenType token.TokenType) int {
+	if precedence, ok := precedences[tokenType]; ok {
 		return precedence
 	}
 
 	return LOWEST
 }
 
+func (p *Parser) peekPrecedence() int {
+	return p.precedence(p.peekToken.Type)
+}
+
 func (p *Parser) currPrecedence() int {
-	if precedence, ok := precedences[p.currToken.Type]; ok {
+	return p.precedence(p.currToken.Type)
+}
+
+// Get the precedence level of an infix operator token for this parser, preferring any set with SetPrecedence
+func (p *Parser) precedence(tokenType token.TokenType) int {
+	if precedence, ok := p.precedences[tokenType]; ok {
 		return precedence
 	}
 
-	return LOWEST
+	return Precedence(tokenType)
+}
+
+// Record an error about the given token
+func (p *Parser) addError(tok token.Token, expected []token.TokenType, message string) {
+	// The parse functions unwinding after parsing was abandoned would otherwise each report an error
+	if p.abandoned {
+		return
+	}
+
+	p.errors = append(p.errors, &ParseError{
+		Pos:      tok.Pos,
+		Token:    tok,
+		Expected: expected,
+		Message:  message,
+	})
+
+	if p.maxErrors > 0 && len(p.errors) == p.maxErrors {
+		p.errors = append(p.errors, &ParseError{
+			Pos:     tok.Pos,
+			Token:   tok,
+			Message: fmt.Sprintf("Too many errors. Stopped after %d", p.maxErrors),
+		})
+		p.abandon()
+	}
 }
 
 func (p *Parser) peekError(t token.TokenType) {
 	message := fmt.Sprintf("Unexpected next token. Expected next token to be %s; got %s", t, p.peekToken.Type)
-	p.errors = append(p.errors, message)
+	p.addError(p.peekToken, []token.TokenType{t}, message)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	message := fmt.Sprintf("Failed to find prefix parse function for token %s", t)
-	p.errors = append(p.errors, message)
+	p.addError(p.currToken, nil, message)
+}
+
+// Enter a nested expression or block, reporting false and abandoning parsing if it's nested too deeply.
+// Each call must be paired with a call to leave
+func (p *Parser) enter() bool {
+	p.depth += 1
+
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		message := fmt.Sprintf("Nested too deeply. Maximum nesting depth is %d", p.maxDepth)
+		p.addError(p.currToken, nil, message)
+		p.abandon()
+		return false
+	}
+
+	return !p.abandoned
+}
+
+func (p *Parser) leave() {
+	p.depth -= 1
+}
+
+// Stop parsing by skipping the rest of the input, letting the parse functions unwind without recursing further
+func (p *Parser) abandon() {
+	p.abandoned = true
+
+	for !p.currTokenIs(token.EOF) {
+		p.nextToken()
+	}
 }
 
 // Advances the parser through the token sequence
 func (p *Parser) nextToken() {
 	p.currToken = p.peekToken
-	p.peekToken = p.lexer.NextToken()
+	p.currDoc = p.peekDoc
+	p.peekToken = p.readToken()
+	p.peekDoc = nil
+
+	// Doc comments aren't part of the grammar; they're set aside for the declaration that follows them
+	for p.peekToken.Type == token.DOCCOMMENT {
+		p.peekDoc = append(p.peekDoc, p.peekToken)
+		p.peekToken = p.readToken()
+	}
+
+	if len(p.currDoc) > 0 && !p.currTokenIs(token.LET) && !p.currTokenIs(token.CONST) {
+		p.addError(p.currDoc[0], []token.TokenType{token.LET, token.CONST}, "Doc comment must precede a let or const statement")
+	}
+}
+
+// Read the next token from the lexer, skipping any trivia produced by a lexer created with lexer.WithTrivia
+func (p *Parser) readToken() token.Token {
+	tok := p.lexer.NextToken()
+	for tok.Type == token.WHITESPACE || tok.Type == token.SHEBANG {
+		tok = p.lexer.NextToken()
+	}
+	p.interpolations += interpolationChange(tok)
+	return tok
+}
+
+// Get the change a token makes to the number of open string interpolations: it opens one if it ends with ${
+// and closes one if it begins with }
+func interpolationChange(tok token.Token) int {
+	switch tok.Type {
+	case token.STRINGHEAD:
+		return 1
+	case token.STRINGTAIL:
+		return -1
+	}
+	return 0
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -126,9 +327,10 @@ func (p *Parser) ParseProgram() *ast.Program {
 	}
 
 	for !p.currTokenIs(token.EOF) {
-		statement := p.parseStatement()
-
-		program.Statements = append(program.Statements, statement)
+		// Statements that failed to parse have already recorded an error, so they're left out of the tree
+		if statement, ok := p.parseStatement(); ok {
+			program.Statements = append(program.Statements, statement)
+		}
 
 		p.nextToken()
 	}
@@ -136,75 +338,337 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
-func (p *Parser) parseStatement() ast.Statement {
+// Parse the input as a single expression, optionally followed by a semicolon. Returns nil if the
+// expression failed to parse or any tokens remain after it, with the problem recorded in Errors
+func (p *Parser) ParseExpression() ast.Expression {
+	expression := p.parseExpression(LOWEST)
+	if expression == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if !p.peekTokenIs(token.EOF) {
+		message := fmt.Sprintf("Unexpected token after expression. Expected EOF; got %s", p.peekToken.Type)
+		p.addError(p.peekToken, []token.TokenType{token.EOF}, message)
+		return nil
+	}
+
+	p.nextToken()
+
+	return expression
+}
+
+// Parse src as a single expression. Convenience for tools that evaluate expressions rather than programs
+func ParseExpressionString(src string) (ast.Expression, []*ParseError) {
+	p := New(lexer.New(src))
+	expression := p.ParseExpression()
+	return expression, p.Errors()
+}
+
+// Parse the statement beginning at the current token, reporting false if it failed to parse or was empty.
+// The concrete parse functions return typed nil pointers on failure, which aren't nil as a Statement.
+//
+// Semicolons terminate statements but may be left out wherever the end of a statement is unambiguous: before a
+// line break, a closing }, the next case or default of a switch, or the end of input, and after a statement that
+// ends with a closing }. Statements that share a line must otherwise be separated by semicolons. A semicolon
+// with no statement before it is an empty statement, which is allowed and left out of the tree
+func (p *Parser) parseStatement() (ast.Statement, bool) {
 	switch p.currToken.Type {
-	case token.LET:
-		return p.parseLetStatement()
+	case token.SEMICOLON:
+		return nil, false
+	case token.LET, token.CONST:
+		statement := p.parseLetStatement()
+		return statement, statement != nil
 	case token.RETURN:
-		return p.parseReturnStatement()
+		statement := p.parseReturnStatement()
+		return statement, statement != nil
+	case token.IMPORT:
+		statement := p.parseImportStatement()
+		return statement, statement != nil
+	case token.FOR:
+		statement := p.parseForStatement()
+		return statement, statement != nil
+	case token.TRY:
+		statement := p.parseTryStatement()
+		return statement, statement != nil
+	case token.BREAK:
+		statement := p.parseBreakStatement()
+		return statement, statement != nil
+	case token.CONTINUE:
+		statement := p.parseContinueStatement()
+		return statement, statement != nil
 	default:
-		return p.parseExpressionStatement()
+		statement := p.parseExpressionStatement()
+		return statement, statement != nil
 	}
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
+	defer p.untrace(p.trace("parseLetStatement"))
+
 	statement := &ast.LetStatement{
 		Token: p.currToken,
+		Doc:   p.currDoc,
 	}
 
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
-	statement.Name = &ast.Identifier{
-		Token: p.currToken,
-		Value: p.currToken.Literal,
-	}
+	statement.Name = p.newIdentifier()
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
 
-	// TODO: Skip over expressions for now
-	for !p.currTokenIs(token.SEMICOLON) {
-		p.nextToken()
+	// Advance past the = and parse the bound value
+	p.nextToken()
+	statement.Value = p.parseExpression(LOWEST)
+	if statement.Value == nil {
+		return nil
 	}
 
+	p.endStatement()
+
 	return statement
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	defer p.untrace(p.trace("parseReturnStatement"))
+
 	statement := &ast.ReturnStatement{
 		Token: p.currToken,
 	}
 
+	// A return value must begin on the same line as return, so return alone on a line returns nothing
+	if !p.peekEndsStatement() {
+		p.nextToken()
+		statement.ReturnValue = p.parseExpression(LOWEST)
+		if statement.ReturnValue == nil {
+			return nil
+		}
+	}
+
+	p.endStatement()
+
+	return statement
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	defer p.untrace(p.trace("parseImportStatement"))
+
+	statement := &ast.ImportStatement{
+		Token: p.currToken,
+	}
+
+	// Only a plain string can be resolved before the program runs, so interpolation isn't allowed
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	statement.Path = &ast.StringLiteral{
+		Token: p.currToken,
+		Value: p.currToken.Literal,
+	}
+
+	p.endStatement()
+
+	return statement
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	defer p.untrace(p.trace("parseForStatement"))
+
+	statement := &ast.ForStatement{
+		Token: p.currToken,
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	statement.Variable = p.newIdentifier()
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	// Advance past the in and parse the expression producing the elements
+	p.nextToken()
+	statement.Iterable = p.parseExpression(LOWEST)
+	if statement.Iterable == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loops += 1
+	statement.Body = p.parseBlockStatement()
+	p.loops -= 1
+	if statement.Body == nil {
+		return nil
+	}
+
+	return statement
+}
+
+// Parse a block followed by a catch clause binding the error raised by it, leaving the parser on the handler's }
+func (p *Parser) parseTryStatement() *ast.TryStatement {
+	defer p.untrace(p.trace("parseTryStatement"))
+
+	statement := &ast.TryStatement{
+		Token: p.currToken,
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Body = p.parseBlockStatement()
+	if statement.Body == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.CATCH) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	statement.Parameter = p.newIdentifier()
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Handler = p.parseBlockStatement()
+	if statement.Handler == nil {
+		return nil
+	}
+
+	return statement
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	defer p.untrace(p.trace("parseBreakStatement"))
+
+	statement := &ast.BreakStatement{
+		Token: p.currToken,
+	}
+
+	p.checkInLoop()
+
+	p.endStatement()
+
+	return statement
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	defer p.untrace(p.trace("parseContinueStatement"))
+
+	statement := &ast.ContinueStatement{
+		Token: p.currToken,
+	}
+
+	p.checkInLoop()
+
+	p.endStatement()
+
+	return statement
+}
+
+// Record an error if the current token, a break or continue, isn't within a loop body. The statement is still
+// parsed, so that tools working with the tree see it where it was written
+func (p *Parser) checkInLoop() {
+	if p.loops > 0 {
+		return
+	}
+
+	message := fmt.Sprintf("Misplaced %s. Only allowed within the body of a for loop", p.currToken.Literal)
+	p.addError(p.currToken, nil, message)
+}
+
+// Parse statements up to the closing brace matching the current {, leaving the parser on the }
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	defer p.untrace(p.trace("parseBlockStatement"))
+
+	defer p.leave()
+	if !p.enter() {
+		return nil
+	}
+
+	block := &ast.BlockStatement{
+		Token:      p.currToken,
+		Statements: []ast.Statement{},
+	}
+
 	p.nextToken()
 
-	// TODO: Skip over expressions for now
-	for !p.currTokenIs(token.SEMICOLON) {
+	for !p.currTokenIs(token.RBRACE) {
+		if p.currTokenIs(token.EOF) {
+			p.addError(p.currToken, []token.TokenType{token.RBRACE}, "Unterminated block. Expected } before end of file")
+			return nil
+		}
+
+		if statement, ok := p.parseStatement(); ok {
+			block.Statements = append(block.Statements, statement)
+		}
+
 		p.nextToken()
 	}
 
-	return statement
+	block.RBrace = p.currToken
+
+	return block
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	defer untrace(trace("parseExpressionStatement"))
+	defer p.untrace(p.trace("parseExpressionStatement"))
 
-	statement := &ast.ExpressionStatement{
+	statement := p.expressionStatements.new()
+	*statement = ast.ExpressionStatement{
 		Token:      p.currToken,
 		Expression: p.parseExpression(LOWEST),
 	}
-
-	if p.peekTokenIs(token.SEMICOLON) {
-		p.nextToken()
+	if statement.Expression == nil {
+		return nil
 	}
 
+	p.endStatement()
+
 	return statement
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
-	defer untrace(trace("parseExpression"))
+	defer p.untrace(p.trace("parseExpression"))
+
+	defer p.leave()
+	if !p.enter() {
+		return nil
+	}
 
 	prefixFn := p.prefixParseFns[p.currToken.Type]
 	if prefixFn == nil {
@@ -212,6 +676,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		return nil
 	}
 	leftExpression := prefixFn()
+	if leftExpression == nil {
+		return nil
+	}
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infixFn := p.infixParseFns[p.peekToken.Type]
@@ -219,32 +686,50 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 			return leftExpression
 		}
 
+		// A ( or [ beginning a line begins a new statement rather than calling or indexing the previous line
+		if (p.peekTokenIs(token.LPAREN) || p.peekTokenIs(token.LBRACKET)) && p.peekOnNewLine() {
+			return leftExpression
+		}
+
 		p.nextToken()
 
 		leftExpression = infixFn(leftExpression)
+		if leftExpression == nil {
+			return nil
+		}
 	}
 
 	return leftExpression
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{
+	defer p.untrace(p.trace("parseIdentifier"))
+
+	return p.newIdentifier()
+}
+
+// Create an identifier node for the current token
+func (p *Parser) newIdentifier() *ast.Identifier {
+	identifier := p.identifiers.new()
+	*identifier = ast.Identifier{
 		Token: p.currToken,
 		Value: p.currToken.Literal,
 	}
+	return identifier
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	defer untrace(trace("parseIntegerLiteral"))
+	defer p.untrace(p.trace("parseIntegerLiteral"))
 
-	literal := &ast.IntegerLiteral{
+	literal := p.integerLiterals.new()
+	*literal = ast.IntegerLiteral{
 		Token: p.currToken,
 	}
 
 	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
 	if err != nil {
 		message := fmt.Sprintf("Failed to parse %q as integer", p.currToken.Literal)
-		p.errors = append(p.errors, message)
+		p.addError(p.currToken, nil, message)
 		return nil
 	}
 
@@ -253,8 +738,56 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return literal
 }
 
+func (p *Parser) parseStringLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseStringLiteral"))
+
+	return &ast.StringLiteral{
+		Token: p.currToken,
+		Value: p.currToken.Literal,
+	}
+}
+
+// Parse the embedded expressions and surrounding text of an interpolated string, starting on its STRINGHEAD
+// and leaving the parser on its STRINGTAIL
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	defer p.untrace(p.trace("parseInterpolatedString"))
+
+	interpolatedString := &ast.InterpolatedString{
+		Token: p.currToken,
+		Parts: []ast.Expression{p.parseStringLiteral()},
+	}
+
+	for !p.currTokenIs(token.STRINGTAIL) {
+		p.nextToken()
+		expression := p.parseExpression(LOWEST)
+		if expression == nil {
+			return nil
+		}
+		interpolatedString.Parts = append(interpolatedString.Parts, expression)
+
+		if !p.peekTokenIs(token.STRINGMIDDLE) && !p.peekTokenIs(token.STRINGTAIL) {
+			message := fmt.Sprintf("Unterminated string interpolation. Expected } before %s", p.peekToken.Type)
+			p.addError(p.peekToken, []token.TokenType{token.STRINGMIDDLE, token.STRINGTAIL}, message)
+			return nil
+		}
+
+		p.nextToken()
+		interpolatedString.Parts = append(interpolatedString.Parts, p.parseStringLiteral())
+	}
+
+	return interpolatedString
+}
+
+func (p *Parser) parseNullLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseNullLiteral"))
+
+	return &ast.NullLiteral{
+		Token: p.currToken,
+	}
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	defer untrace(trace("parsePrefixExpression"))
+	defer p.untrace(p.trace("parsePrefixExpression"))
 
 	prefixExpression := &ast.PrefixExpression{
 		Token:    p.currToken,
@@ -264,14 +797,134 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	// Advance the parser and parse the prefix expression's operand as an expression
 	p.nextToken()
 	prefixExpression.Right = p.parseExpression(PREFIX)
+	if prefixExpression.Right == nil {
+		return nil
+	}
 
 	return prefixExpression
 }
 
+// Parse an expression wrapped in parentheses, which only serve to override precedence
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	defer p.untrace(p.trace("parseGroupedExpression"))
+
+	p.nextToken()
+
+	expression := p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	p.grouped = expression
+	return expression
+}
+
+func (p *Parser) parseSwitchExpression() ast.Expression {
+	defer p.untrace(p.trace("parseSwitchExpression"))
+
+	switchExpression := &ast.SwitchExpression{
+		Token: p.currToken,
+		Cases: []*ast.SwitchCase{},
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	switchExpression.Subject = p.parseExpression(LOWEST)
+	if switchExpression.Subject == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.nextToken()
+
+	hasDefault := false
+
+	for !p.currTokenIs(token.RBRACE) {
+		switchCase := p.parseSwitchCase()
+		if switchCase == nil {
+			return nil
+		}
+
+		if switchCase.IsDefault() {
+			if hasDefault {
+				p.addError(switchCase.Token, nil, "Duplicate default case in switch")
+				return nil
+			}
+			hasDefault = true
+		}
+
+		switchExpression.Cases = append(switchExpression.Cases, switchCase)
+	}
+
+	switchExpression.RBrace = p.currToken
+
+	return switchExpression
+}
+
+// Parse a case or default branch, leaving the parser on the token that begins the next branch or the closing }
+func (p *Parser) parseSwitchCase() *ast.SwitchCase {
+	defer p.untrace(p.trace("parseSwitchCase"))
+
+	switchCase := &ast.SwitchCase{
+		Token: p.currToken,
+	}
+
+	switch p.currToken.Type {
+	case token.CASE:
+		p.nextToken()
+		switchCase.Value = p.parseExpression(LOWEST)
+		if switchCase.Value == nil {
+			return nil
+		}
+	case token.DEFAULT:
+	case token.EOF:
+		p.addError(p.currToken, []token.TokenType{token.RBRACE}, "Unterminated switch. Expected } before end of file")
+		return nil
+	default:
+		message := fmt.Sprintf("Unexpected token in switch. Expected case or default; got %s", p.currToken.Type)
+		p.addError(p.currToken, []token.TokenType{token.CASE, token.DEFAULT}, message)
+		return nil
+	}
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	switchCase.Body = &ast.BlockStatement{
+		Token:      p.currToken,
+		Statements: []ast.Statement{},
+	}
+
+	// The body runs until the next branch or the end of the switch rather than a closing brace
+	for !p.peekTokenIs(token.CASE) && !p.peekTokenIs(token.DEFAULT) && !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+
+		if statement, ok := p.parseStatement(); ok {
+			switchCase.Body.Statements = append(switchCase.Body.Statements, statement)
+		}
+	}
+
+	p.nextToken()
+
+	return switchCase
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	defer untrace(trace("parseInfixExpression"))
+	defer p.untrace(p.trace("parseInfixExpression"))
 
-	infixExpression := &ast.InfixExpression{
+	infixExpression := p.infixExpressions.new()
+	*infixExpression = ast.InfixExpression{
 		Token:    p.currToken,
 		Operator: p.currToken.Literal,
 		Left:     left,
@@ -282,10 +935,234 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	// Advance the parser and parse the right operand's expression based on precedence of the operator
 	p.nextToken()
 	infixExpression.Right = p.parseExpression(operatorPrecedence)
+	if infixExpression.Right == nil {
+		return nil
+	}
+
+	// 1 < x < 10 would compare the boolean 1 < x with 10, which is rarely what's meant, so the first comparison
+	// must be parenthesised
+	if chained, ok := left.(*ast.InfixExpression); ok && left != p.grouped && IsComparison(chained.Token.Type) && IsComparison(infixExpression.Token.Type) {
+		message := fmt.Sprintf("Comparisons don't chain. This compares the result of %s with %s; compare each pair separately, or parenthesise the first comparison if that's intended",
+			chained, infixExpression.Right)
+		p.addError(infixExpression.Token, nil, message)
+	}
 
 	return infixExpression
 }
 
+// Report whether a token is a comparison operator, < or >, which can't follow another comparison without
+// parentheses
+func IsComparison(tokenType token.TokenType) bool {
+	return Precedence(tokenType) == LESSGREATER
+}
+
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseAssignExpression"))
+
+	assignExpression := &ast.AssignExpression{
+		Token:    p.currToken,
+		Target:   target,
+		Operator: p.currToken.Literal,
+	}
+
+	// Parse the value one level below ASSIGN so that assignment is right-associative, i.e., a = b = c is a = (b = c)
+	p.nextToken()
+	assignExpression.Value = p.parseExpression(ASSIGN - 1)
+	if assignExpression.Value == nil {
+		return nil
+	}
+
+	// Checked after parsing the value so that the whole assignment is skipped rather than reparsed from the =
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		message := fmt.Sprintf("Invalid assignment target %s. Expected an identifier or index expression", target.String())
+		p.addError(assignExpression.Token, nil, message)
+		return nil
+	}
+
+	return assignExpression
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseCallExpression"))
+
+	callExpression := &ast.CallExpression{
+		Token:    p.currToken,
+		Function: function,
+	}
+
+	callExpression.Arguments = p.parseCallArguments()
+	if callExpression.Arguments == nil {
+		return nil
+	}
+
+	callExpression.RParen = p.currToken
+
+	return callExpression
+}
+
+// Parse a comma-separated argument list, starting on the ( and leaving the parser on the ). The last argument may
+// be followed by a comma, so that arguments split over several lines can each end with one.
+// Returns nil if any argument fails to parse
+func (p *Parser) parseCallArguments() []ast.Expression {
+	defer p.untrace(p.trace("parseCallArguments"))
+
+	arguments := []ast.Expression{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return arguments
+	}
+
+	p.nextToken()
+	argument := p.parseExpression(LOWEST)
+	if argument == nil {
+		return nil
+	}
+	arguments = append(arguments, argument)
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
+
+		// Advance past the comma
+		p.nextToken()
+
+		argument := p.parseExpression(LOWEST)
+		if argument == nil {
+			return nil
+		}
+		arguments = append(arguments, argument)
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return arguments
+}
+
+// Parse a pipeline, desugaring it into a call with the left operand injected as the first argument,
+// i.e., x |> f(y) becomes f(x, y), and x |> f becomes f(x)
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parsePipeExpression"))
+
+	pipeToken := p.currToken
+
+	p.nextToken()
+	right := p.parseExpression(PIPE)
+	if right == nil {
+		return nil
+	}
+
+	if callExpression, ok := right.(*ast.CallExpression); ok {
+		callExpression.Arguments = append([]ast.Expression{left}, callExpression.Arguments...)
+		return callExpression
+	}
+
+	return &ast.CallExpression{
+		Token:     pipeToken,
+		Function:  right,
+		Arguments: []ast.Expression{left},
+	}
+}
+
+// Parse member access, e.g., person.name, as an index expression with a string key
+func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseDotExpression"))
+
+	indexExpression := &ast.IndexExpression{
+		Token: p.currToken,
+		Left:  left,
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	indexExpression.Index = &ast.StringLiteral{
+		Token: token.Token{Type: token.STRING, Literal: p.currToken.Literal, Pos: p.currToken.Pos, End: p.currToken.End},
+		Value: p.currToken.Literal,
+	}
+
+	return indexExpression
+}
+
+// Parse an index, e.g., 'arr[i]', or a slice, e.g., 'arr[1:3]', whose bounds may be omitted
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseIndexExpression"))
+
+	bracket := p.currToken
+	p.nextToken()
+
+	var low ast.Expression
+	if !p.currTokenIs(token.COLON) {
+		low = p.parseExpression(LOWEST)
+		if low == nil {
+			return nil
+		}
+
+		if !p.peekTokenIs(token.COLON) {
+			if !p.expectPeek(token.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: bracket, Left: left, Index: low, RBracket: p.currToken}
+		}
+
+		p.nextToken()
+	}
+
+	sliceExpression := &ast.SliceExpression{Token: bracket, Left: left, Low: low}
+
+	// Current token is the :, which may be followed directly by the ]
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		sliceExpression.High = p.parseExpression(LOWEST)
+		if sliceExpression.High == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	sliceExpression.RBracket = p.currToken
+
+	return sliceExpression
+}
+
+func (p *Parser) parseTernaryExpression(condition ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseTernaryExpression"))
+
+	ternaryExpression := &ast.TernaryExpression{
+		Token:     p.currToken,
+		Condition: condition,
+	}
+
+	// The consequence is delimited by the : so it can contain any expression
+	p.nextToken()
+	ternaryExpression.Consequence = p.parseExpression(LOWEST)
+	if ternaryExpression.Consequence == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	// Parse the alternative one level below TERNARY so that a ? b : c ? d : e is a ? b : (c ? d : e)
+	p.nextToken()
+	ternaryExpression.Alternative = p.parseExpression(TERNARY - 1)
+	if ternaryExpression.Alternative == nil {
+		return nil
+	}
+
+	return ternaryExpression
+}
+
 // Compare type of current token to expected
 func (p *Parser) currTokenIs(t token.TokenType) bool {
 	return p.currToken.Type == t
@@ -296,6 +1173,38 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 	return p.peekToken.Type == t
 }
 
+// Report whether the next token is on a later line than the current token ends on
+func (p *Parser) peekOnNewLine() bool {
+	return p.peekToken.Pos.Line > p.currToken.End.Line
+}
+
+// Report whether the statement ending at the current token may end without a semicolon, as the next token
+// can't continue it
+func (p *Parser) peekEndsStatement() bool {
+	switch p.peekToken.Type {
+	case token.SEMICOLON, token.RBRACE, token.CASE, token.DEFAULT, token.EOF:
+		return true
+	}
+
+	return p.peekOnNewLine()
+}
+
+// Finish the statement ending at the current token, consuming its semicolon if it has one or recording an error
+// if it needs one but doesn't
+func (p *Parser) endStatement() {
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		return
+	}
+
+	if p.peekEndsStatement() || p.currTokenIs(token.RBRACE) {
+		return
+	}
+
+	message := fmt.Sprintf("Missing semicolon. Expected ; or a line break before %s", p.peekToken.Type)
+	p.addError(p.peekToken, []token.TokenType{token.SEMICOLON}, message)
+}
+
 // Asserts the next token and advances the parser if the assertion is true.
 // Instead logs an error if the assertion is false.
 func (p *Parser) expectPeek(t token.TokenType) bool {
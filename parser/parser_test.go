@@ -2,8 +2,12 @@ package parser
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"rowanlovejoy/monkey/ast"
 	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -22,16 +26,62 @@ func TestLetStatements(t *testing.T) {
 
 	tests := []struct {
 		expectedIdentifier string
+		expectedValue      int64
 	}{
-		{"x"},
-		{"y"},
-		{"foobar"},
+		{"x", 5},
+		{"y", 10},
+		{"foobar", 838383},
 	}
 
 	for i, test := range tests {
 		if !testLetStatement(t, program.Statements[i], test.expectedIdentifier) {
 			return
 		}
+
+		letStatement := program.Statements[i].(*ast.LetStatement)
+		if !testIntegerLiteral(t, letStatement.Value, test.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	input := `
+		const x = 5;
+		let y = 10;
+	`
+	parser := New(lexer.New(input))
+
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 2)
+
+	tests := []struct {
+		expectedLiteral  string
+		expectedConstant bool
+	}{
+		{"const", true},
+		{"let", false},
+	}
+
+	for i, test := range tests {
+		statement, ok := program.Statements[i].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("Unexpected statement type. Expected *ast.LetStatement; got %T", program.Statements[i])
+		}
+
+		if literal := statement.TokenLiteral(); literal != test.expectedLiteral {
+			t.Errorf("Unexpected token literal. Expected %q; got %q", test.expectedLiteral, literal)
+		}
+
+		if constant := statement.Constant(); constant != test.expectedConstant {
+			t.Errorf("Unexpected constness for %q. Expected %t; got %t", statement.String(), test.expectedConstant, constant)
+		}
+	}
+
+	if actual := program.String(); actual != "const x = 5;let y = 10;" {
+		t.Errorf("Unexpected string output. Expected %q; got %q", "const x = 5;let y = 10;", actual)
 	}
 }
 
@@ -48,7 +98,9 @@ func TestReturnStatements(t *testing.T) {
 	checkParserErrors(t, parser)
 	checkStatementCount(t, program, 3)
 
-	for _, statement := range program.Statements {
+	expectedValues := []int64{5, 10, 993322}
+
+	for i, statement := range program.Statements {
 		returnStatement, ok := statement.(*ast.ReturnStatement)
 		if !ok {
 			t.Errorf("Unexpected statement type. Expected *ast.ReturnStatement; got %T", returnStatement)
@@ -57,6 +109,133 @@ func TestReturnStatements(t *testing.T) {
 		if literal := returnStatement.TokenLiteral(); literal != "return" {
 			t.Errorf("Unexpected return statement token literal. Expected \"return\". Got %q", literal)
 		}
+		testIntegerLiteral(t, returnStatement.ReturnValue, expectedValues[i])
+	}
+}
+
+func TestBareReturnStatements(t *testing.T) {
+	tests := []string{
+		"return;",
+		"return",
+		"return\n",
+		"for (x in xs) { return }",
+		"switch (x) { case 1: return default: return }",
+	}
+
+	for _, input := range tests {
+		parser := New(lexer.New(input))
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		ast.Inspect(program, func(node ast.Node) bool {
+			if statement, ok := node.(*ast.ReturnStatement); ok && statement.ReturnValue != nil {
+				t.Errorf("Unexpected return value for %q. Expected none; got %s", input, statement.ReturnValue)
+			}
+			return true
+		})
+	}
+}
+
+func TestImportStatements(t *testing.T) {
+	input := `
+		import "lib/strings";
+		import "../shared"
+	`
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 2)
+
+	expectedPaths := []string{"lib/strings", "../shared"}
+
+	for i, statement := range program.Statements {
+		importStatement, ok := statement.(*ast.ImportStatement)
+		if !ok {
+			t.Errorf("Unexpected statement type. Expected *ast.ImportStatement; got %T", statement)
+			continue
+		}
+		if path := importStatement.Path.Value; path != expectedPaths[i] {
+			t.Errorf("Unexpected import path. Expected %q; got %q", expectedPaths[i], path)
+		}
+	}
+}
+
+func TestMalformedImportStatements(t *testing.T) {
+	inputs := []string{
+		"import;",
+		"import lib;",
+		`import "a ${b}";`,
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `
+		/// Number of retries
+		///
+		///   before giving up
+		const retries = 3;
+		let undocumented = 1;
+		for (i in 1..retries) {
+			/// Attempt number
+			let attempt = i;
+		}
+	`
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 3)
+
+	tests := []struct {
+		statement ast.Statement
+		expected  string
+	}{
+		{program.Statements[0], "Number of retries\n\n  before giving up\n"},
+		{program.Statements[1], ""},
+		{program.Statements[2].(*ast.ForStatement).Body.Statements[0], "Attempt number\n"},
+	}
+
+	for _, tt := range tests {
+		letStatement, ok := tt.statement.(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("Unexpected statement type. Expected *ast.LetStatement; got %T", tt.statement)
+		}
+		if doc := letStatement.DocText(); doc != tt.expected {
+			t.Errorf("Unexpected doc text for %s. Expected %q; got %q", letStatement.Name.Value, tt.expected, doc)
+		}
+	}
+
+	// The doc comment isn't part of the statement's span
+	if pos := program.Statements[0].Pos(); pos.Line != 5 {
+		t.Errorf("Unexpected statement start. Expected line 5; got %s", pos)
+	}
+}
+
+func TestMalformedDocComments(t *testing.T) {
+	inputs := []string{
+		"/// Stray\nx;",
+		"let x = 1 + /// Inside\n2;",
+		"let x = 1;\n/// Trailing",
+		"/// Before a loop\nfor (i in 1..2) {}",
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
 	}
 }
 
@@ -123,6 +302,117 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Unexpected statement type. Expected *ast.ExpressionStatement; got %T", program.Statements[0])
+	}
+
+	stringLiteral, ok := statement.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("Unexpected expression type. Expected *ast.StringLiteral; got %T", statement.Expression)
+	}
+
+	if value := stringLiteral.Value; value != "hello world" {
+		t.Errorf("Unexpected literal value. Expected %q; got %q", "hello world", value)
+	}
+}
+
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"total: ${x + y}, first: ${f(a)}"`
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Unexpected statement type. Expected *ast.ExpressionStatement; got %T", program.Statements[0])
+	}
+
+	interpolatedString, ok := statement.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("Unexpected expression type. Expected *ast.InterpolatedString; got %T", statement.Expression)
+	}
+
+	expectedParts := []string{`"total: "`, "(x + y)", `", first: "`, "f(a)", `""`}
+	if count := len(interpolatedString.Parts); count != len(expectedParts) {
+		t.Fatalf("Unexpected part count. Expected %d; got %d", len(expectedParts), count)
+	}
+
+	for i, expected := range expectedParts {
+		part := interpolatedString.Parts[i]
+
+		// Text and embedded expressions alternate, starting with text
+		if _, isText := part.(*ast.StringLiteral); isText != (i%2 == 0) {
+			t.Errorf("Unexpected part type at %d: %T", i, part)
+		}
+
+		if actual := part.String(); actual != expected {
+			t.Errorf("Unexpected part %d. Expected %q; got %q", i, expected, actual)
+		}
+	}
+
+	expectedString := `"total: ${(x + y)}, first: ${f(a)}"`
+	if actual := program.String(); actual != expectedString {
+		t.Errorf("Unexpected string output. Expected %q; got %q", expectedString, actual)
+	}
+}
+
+func TestMalformedStrings(t *testing.T) {
+	inputs := []string{
+		`"unterminated`,
+		`"${}"`,
+		`"${x y}"`,
+		`"${x`,
+		`"a ${x + }"`,
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
+	}
+}
+
+func TestNullLiteralExpression(t *testing.T) {
+	input := `
+		null;
+	`
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Unexpected statement type. Expected *ast.ExpressionStatement; got %T", program.Statements[0])
+	}
+
+	nullLiteral, ok := statement.Expression.(*ast.NullLiteral)
+	if !ok {
+		t.Fatalf("Unexpected expression type. Expected *ast.NullLiteral; got %T", statement.Expression)
+	}
+
+	if literal := nullLiteral.TokenLiteral(); literal != "null" {
+		t.Errorf("Unexpected token literal. Expected %q; got %q", "null", literal)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -131,6 +421,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5", "!", 5},
 		{"-15", "-", 15},
+		{"~15", "~", 15},
 	}
 
 	for _, test := range prefixTests {
@@ -175,6 +466,13 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5", 5, "<", 5},
 		{"5 == 5", 5, "==", 5},
 		{"5 != 5", 5, "!=", 5},
+		{"5..5", 5, "..", 5},
+		{"5 & 5", 5, "&", 5},
+		{"5 | 5", 5, "|", 5},
+		{"5 ^ 5", 5, "^", 5},
+		{"5 << 5", 5, "<<", 5},
+		{"5 >> 5", 5, ">>", 5},
+		{"5 in 5", 5, "in", 5},
 	}
 
 	for _, test := range infixTests {
@@ -261,6 +559,110 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3 + 4 * 5 == 3 * 1 + 4 * 5",
 			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
 		},
+		{
+			"1 + (2 + 3) + 4",
+			"((1 + (2 + 3)) + 4)",
+		},
+		{
+			"(5 + 5) * 2",
+			"((5 + 5) * 2)",
+		},
+		{
+			"2 / (5 + 5)",
+			"(2 / (5 + 5))",
+		},
+		{
+			"-(5 + 5)",
+			"(-(5 + 5))",
+		},
+		{
+			"!(a == b)",
+			"(!(a == b))",
+		},
+		{
+			"x == null != !null",
+			"((x == null) != (!null))",
+		},
+		{
+			"1..10",
+			"(1 .. 10)",
+		},
+		{
+			"1..n + 1",
+			"(1 .. (n + 1))",
+		},
+		{
+			"a * 2..b - 1",
+			"((a * 2) .. (b - 1))",
+		},
+		{
+			"-a..b",
+			"((-a) .. b)",
+		},
+		{
+			"1..2..3",
+			"((1 .. 2) .. 3)",
+		},
+		{
+			"0..n < 1..m",
+			"((0 .. n) < (1 .. m))",
+		},
+		{
+			"(1..3) * 2",
+			"((1 .. 3) * 2)",
+		},
+		{
+			"a | b ^ c & d",
+			"(a | (b ^ (c & d)))",
+		},
+		{
+			"a & b << c + d",
+			"(a & (b << (c + d)))",
+		},
+		{
+			"a << b >> c",
+			"((a << b) >> c)",
+		},
+		{
+			"a & b == c | d",
+			"((a & b) == (c | d))",
+		},
+		{
+			"0..n << 1",
+			"(0 .. (n << 1))",
+		},
+		{
+			"~a & -b",
+			"((~a) & (-b))",
+		},
+		{
+			"x |> f | g",
+			"(f | g)(x)",
+		},
+		{
+			"x in 1..10",
+			"(x in (1 .. 10))",
+		},
+		{
+			"a in b == c in d",
+			"((a in b) == (c in d))",
+		},
+		{
+			"a < b in c > d",
+			"((a < b) in (c > d))",
+		},
+		{
+			"a in b in c",
+			"((a in b) in c)",
+		},
+		{
+			"!a in b",
+			"((!a) in b)",
+		},
+		{
+			"key in keys |> f",
+			"f((key in keys))",
+		},
 	}
 
 	for _, test := range tests {
@@ -274,63 +676,1300 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
-func testLetStatement(t *testing.T, statement ast.Statement, identifier string) bool {
-	if statement.TokenLiteral() != "let" {
-		t.Errorf("Unexpected token literal. Expected \"let\". Got %q", statement.TokenLiteral())
-		return false
-	}
+func TestForStatement(t *testing.T) {
+	input := `for (x in xs) { x; let y = x * 2; }`
 
-	letStatement, ok := statement.(*ast.LetStatement)
-	if !ok {
-		t.Errorf("Unexpected statement type. Expected *ast.LetStatement. Got %T", statement)
-		return false
-	}
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
 
-	if name := letStatement.Name.Value; name != identifier {
-		t.Errorf("Unexpected let statement name. Expected %q. Got %q", identifier, name)
-		return false
-	}
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
 
-	if literal := letStatement.Name.TokenLiteral(); literal != identifier {
-		t.Errorf("Unexpected let statement token literal. Expected %q. Got %q", identifier, literal)
-		return false
+	statement, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("Unexpected statement type. Expected *ast.ForStatement; got %T", program.Statements[0])
 	}
 
-	return true
-}
+	if name := statement.Variable.Value; name != "x" {
+		t.Errorf("Unexpected loop variable. Expected %q; got %q", "x", name)
+	}
 
-func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
-	integerLiteral, ok := il.(*ast.IntegerLiteral)
+	iterable, ok := statement.Iterable.(*ast.Identifier)
 	if !ok {
-		t.Errorf("Unexpected expression type. Expected *ast.IntegerLiteral; got %T", il)
-		return false
+		t.Fatalf("Unexpected iterable type. Expected *ast.Identifier; got %T", statement.Iterable)
 	}
-
-	if literalValue := integerLiteral.Value; literalValue != value {
-		t.Errorf("Unexpected literal value. Expected %d; got %d", value, literalValue)
-		return false
+	if iterable.Value != "xs" {
+		t.Errorf("Unexpected iterable. Expected %q; got %q", "xs", iterable.Value)
 	}
 
-	if tokenLiteral := integerLiteral.TokenLiteral(); tokenLiteral != fmt.Sprintf("%d", value) {
-		t.Errorf("Unexpected token literal. Expected %d; got %q", value, tokenLiteral)
-		return false
+	if count := len(statement.Body.Statements); count != 2 {
+		t.Fatalf("Unexpected body statement count. Expected 2; got %d", count)
 	}
 
-	return true
-}
-
-func checkParserErrors(t *testing.T, p *Parser) {
-	errors := p.Errors()
-
-	if len(errors) == 0 {
+	if !testLetStatement(t, statement.Body.Statements[1], "y") {
 		return
 	}
+}
 
-	t.Errorf("Parser has %d error(s)", len(errors))
-
-	for _, message := range errors {
-		t.Errorf("Parser error: %q", message)
-	}
+func TestForStatementMembershipIterable(t *testing.T) {
+	// The in following the loop variable separates it from the iterable, which may itself use in
+	input := `for (x in a in b) { x; }`
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("Unexpected statement type. Expected *ast.ForStatement; got %T", program.Statements[0])
+	}
+
+	if iterable := statement.Iterable.String(); iterable != "(a in b)" {
+		t.Errorf("Unexpected iterable. Expected %q; got %q", "(a in b)", iterable)
+	}
+}
+
+func TestTryStatement(t *testing.T) {
+	input := `try { risky(); } catch (e) { log(e); }`
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	expected := &ast.TryStatement{
+		Body: &ast.BlockStatement{Statements: []ast.Statement{
+			&ast.ExpressionStatement{Expression: &ast.CallExpression{Function: &ast.Identifier{Value: "risky"}}},
+		}},
+		Parameter: &ast.Identifier{Value: "e"},
+		Handler: &ast.BlockStatement{Statements: []ast.Statement{
+			&ast.ExpressionStatement{Expression: &ast.CallExpression{
+				Function:  &ast.Identifier{Value: "log"},
+				Arguments: []ast.Expression{&ast.Identifier{Value: "e"}},
+			}},
+		}},
+	}
+
+	if diff := ast.Diff(expected, program.Statements[0]); diff != "" {
+		t.Errorf("Unexpected tree. %s", diff)
+	}
+}
+
+func TestMalformedTryStatements(t *testing.T) {
+	inputs := []string{
+		"try x; catch (e) { y; }",
+		"try { x; }",
+		"try { x; } catch { y; }",
+		"try { x; } catch (1) { y; }",
+		"try { x; } catch (e) y;",
+		"try { x; } catch (e) { y;",
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		program := parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
+
+		for _, statement := range program.Statements {
+			if _, ok := statement.(*ast.TryStatement); ok {
+				t.Errorf("Unexpected try statement parsed from %q", input)
+			}
+		}
+	}
+}
+
+func TestMalformedForStatements(t *testing.T) {
+	inputs := []string{
+		"for x in xs { x; }",
+		"for (1 in xs) { x; }",
+		"for (x xs) { x; }",
+		"for (x in xs) x;",
+		"for (x in xs) { x;",
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		program := parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
+
+		for _, statement := range program.Statements {
+			if _, ok := statement.(*ast.ForStatement); ok {
+				t.Errorf("Unexpected for statement parsed from %q", input)
+			}
+		}
+	}
+}
+
+func TestBreakAndContinueStatements(t *testing.T) {
+	input := `for (x in xs) {
+		switch (x) { case 1: continue default: break }
+		try { break; } catch (e) { continue }
+		for (y in x) { break }
+	}`
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	var jumps []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.BreakStatement, *ast.ContinueStatement:
+			jumps = append(jumps, node.String())
+		}
+		return true
+	})
+
+	expected := []string{"continue;", "break;", "break;", "continue;", "break;"}
+	if fmt.Sprint(jumps) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected jump statements. Expected %v; got %v", expected, jumps)
+	}
+}
+
+func TestMisplacedBreakAndContinueStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		column   int
+	}{
+		{"break", "Misplaced break. Only allowed within the body of a for loop", 1},
+		{"continue;", "Misplaced continue. Only allowed within the body of a for loop", 1},
+		{"try { break } catch (e) { x }", "Misplaced break. Only allowed within the body of a for loop", 7},
+		{"for (x in xs) { x }; continue", "Misplaced continue. Only allowed within the body of a for loop", 22},
+		{"for (x in (switch (y) { default: break })) { x }", "Misplaced break. Only allowed within the body of a for loop", 34},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		program := parser.ParseProgram()
+		errors := parser.Errors()
+
+		if len(errors) != 1 {
+			t.Fatalf("Unexpected error count for %q. Expected 1; got %d: %v", test.input, len(errors), errors)
+		}
+		if errors[0].Message != test.expected {
+			t.Errorf("Unexpected error message for %q. Expected %q; got %q", test.input, test.expected, errors[0].Message)
+		}
+		if errors[0].Pos.Column != test.column {
+			t.Errorf("Unexpected error column for %q. Expected %d; got %d", test.input, test.column, errors[0].Pos.Column)
+		}
+
+		// The misplaced statement is kept in the tree
+		if len(program.Statements) == 0 {
+			t.Errorf("Unexpected empty program for %q", test.input)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5;", "(x = 5)"},
+		{"x = x + 1;", "(x = (x + 1))"},
+		{"x = y = 1;", "(x = (y = 1))"},
+		{"x = -y * 2 == 4;", "(x = (((-y) * 2) == 4))"},
+		{"let a = b = 3;", "let a = (b = 3);"},
+		{"person.name = 5;", "((person.name) = 5)"},
+		{"a.b.c += 1;", "(((a.b).c) += 1)"},
+		{"x += 2;", "(x += 2)"},
+		{"x -= y * 2;", "(x -= (y * 2))"},
+		{"x *= 2 + 1;", "(x *= (2 + 1))"},
+		{"x /= 2;", "(x /= 2)"},
+		{"x = y += 1;", "(x = (y += 1))"},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if actual := program.String(); actual != test.expected {
+			t.Errorf("Unexpected string output. Expected %q; got %q", test.expected, actual)
+		}
+	}
+}
+
+// A compound assignment's target appears once in the tree, so a rewrite applies to each of its nodes only once
+func TestCompoundAssignTargetRewrittenOnce(t *testing.T) {
+	parser := New(lexer.New("a[i].b += 1;"))
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		if identifier, ok := node.(*ast.Identifier); ok {
+			identifier.Value += "_"
+		}
+		return node
+	})
+
+	expected := "(((a_[i_]).b) += 1)"
+	if actual := program.String(); actual != expected {
+		t.Errorf("Unexpected string output. Expected %q; got %q", expected, actual)
+	}
+}
+
+func TestInvalidAssignTargets(t *testing.T) {
+	inputs := []string{
+		"5 = 3;",
+		"a + b = c;",
+		"-a = 1;",
+		"5 += 1;",
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		program := parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
+
+		if count := len(program.Statements); count != 0 {
+			t.Errorf("Unexpected statements parsed from %q: %q", input, program.String())
+		}
+	}
+}
+
+func TestCallExpression(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Unexpected statement type. Expected *ast.ExpressionStatement; got %T", program.Statements[0])
+	}
+
+	callExpression, ok := statement.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("Unexpected expression type. Expected *ast.CallExpression; got %T", statement.Expression)
+	}
+
+	if function := callExpression.Function.String(); function != "add" {
+		t.Errorf("Unexpected function. Expected %q; got %q", "add", function)
+	}
+
+	expectedArguments := []string{"1", "(2 * 3)", "(4 + 5)"}
+	if count := len(callExpression.Arguments); count != len(expectedArguments) {
+		t.Fatalf("Unexpected argument count. Expected %d; got %d", len(expectedArguments), count)
+	}
+
+	for i, expected := range expectedArguments {
+		if argument := callExpression.Arguments[i].String(); argument != expected {
+			t.Errorf("Unexpected argument %d. Expected %q; got %q", i, expected, argument)
+		}
+	}
+}
+
+func TestCallAndPipeExpressionPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
+		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
+		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		{"f()", "f()"},
+		{"f(a,)", "f(a)"},
+		{"f(a, b + c,)", "f(a, (b + c))"},
+		{"f(\n\ta,\n\tb * c,\n) + d", "(f(a, (b * c)) + d)"},
+		{"x |> f(y,)", "f(x, y)"},
+		{"-f(x,)", "(-f(x))"},
+		{"a.b(c)", "(a.b)(c)"},
+		{"f(x)(y)", "f(x)(y)"},
+		{"x |> f", "f(x)"},
+		{"x |> f()", "f(x)"},
+		{"x |> f(y)", "f(x, y)"},
+		{"data |> filter(p) |> map(g)", "map(filter(data, p), g)"},
+		{"a + 1 |> f", "f((a + 1))"},
+		{"x == y |> f", "f((x == y))"},
+		{"y = x |> f", "(y = f(x))"},
+		{"x |> a.b", "(a.b)(x)"},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if actual := program.String(); actual != test.expected {
+			t.Errorf("Unexpected string output. Expected %q; got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestChainedComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Expected error message, or empty if the input parses
+		column   int
+	}{
+		{"1 < x < 10", "Comparisons don't chain. This compares the result of (1 < x) with 10; compare each pair separately, or parenthesise the first comparison if that's intended", 7},
+		{"a > b > c", "Comparisons don't chain. This compares the result of (a > b) with c; compare each pair separately, or parenthesise the first comparison if that's intended", 7},
+		{"a < b + 1 > c", "Comparisons don't chain. This compares the result of (a < (b + 1)) with c; compare each pair separately, or parenthesise the first comparison if that's intended", 11},
+		{"(1 < x) < 10", "", 0},
+		{"1 < (x < 10)", "", 0},
+		{"a < b == c > d", "", 0},
+		{"a == b == c", "", 0},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		parser.ParseProgram()
+		errors := parser.Errors()
+
+		if test.expected == "" {
+			checkParserErrors(t, parser)
+			continue
+		}
+
+		if len(errors) != 1 {
+			t.Fatalf("Unexpected error count for %q. Expected 1; got %d: %v", test.input, len(errors), errors)
+		}
+		if errors[0].Message != test.expected {
+			t.Errorf("Unexpected error message for %q. Expected %q; got %q", test.input, test.expected, errors[0].Message)
+		}
+		if errors[0].Pos.Column != test.column {
+			t.Errorf("Unexpected error column for %q. Expected %d; got %d", test.input, test.column, errors[0].Pos.Column)
+		}
+	}
+}
+
+func TestMalformedCallExpressions(t *testing.T) {
+	inputs := []string{
+		"f(",
+		"f(a",
+		"f(a b)",
+		"f(,)",
+		"f(a,,)",
+		"f(a,",
+		"x |>",
+		"x | > f",
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
+	}
+}
+
+func TestDotExpressions(t *testing.T) {
+	input := "person.name"
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Unexpected statement type. Expected *ast.ExpressionStatement; got %T", program.Statements[0])
+	}
+
+	indexExpression, ok := statement.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("Unexpected expression type. Expected *ast.IndexExpression; got %T", statement.Expression)
+	}
+
+	if left := indexExpression.Left.String(); left != "person" {
+		t.Errorf("Unexpected indexed expression. Expected %q; got %q", "person", left)
+	}
+
+	key, ok := indexExpression.Index.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("Unexpected index type. Expected *ast.StringLiteral; got %T", indexExpression.Index)
+	}
+
+	if key.Value != "name" {
+		t.Errorf("Unexpected key. Expected %q; got %q", "name", key.Value)
+	}
+}
+
+func TestDotExpressionPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a.b.c", "((a.b).c)"},
+		{"-a.b", "(-(a.b))"},
+		{"a.b * c.d", "((a.b) * (c.d))"},
+		{"(a + b).c", "((a + b).c)"},
+		{"a.b..c.d", "((a.b) .. (c.d))"},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if actual := program.String(); actual != test.expected {
+			t.Errorf("Unexpected string output. Expected %q; got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestMalformedDotExpressions(t *testing.T) {
+	inputs := []string{
+		"a.",
+		"a.1",
+		"a.(b)",
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
+	}
+}
+
+func TestIndexExpressions(t *testing.T) {
+	input := "words[1 + 1]"
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Unexpected statement type. Expected *ast.ExpressionStatement; got %T", program.Statements[0])
+	}
+
+	indexExpression, ok := statement.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("Unexpected expression type. Expected *ast.IndexExpression; got %T", statement.Expression)
+	}
+
+	if left := indexExpression.Left.String(); left != "words" {
+		t.Errorf("Unexpected indexed expression. Expected %q; got %q", "words", left)
+	}
+
+	if index := indexExpression.Index.String(); index != "(1 + 1)" {
+		t.Errorf("Unexpected index. Expected %q; got %q", "(1 + 1)", index)
+	}
+
+	if end := indexExpression.End(); end.Offset != len(input) {
+		t.Errorf("Unexpected end offset. Expected %d; got %d", len(input), end.Offset)
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"arr[1:3]", "(arr[1:3])"},
+		{"arr[:2]", "(arr[:2])"},
+		{"s[2:]", "(s[2:])"},
+		{"s[:]", "(s[:])"},
+		{"a[x ? 1 : 2 : n - 1]", "(a[(x ? 1 : 2):(n - 1)])"},
+		{"a.b[1:][0]", "(((a.b)[1:])[0])"},
+		{"-a[1:2] + b[3]", "((-(a[1:2])) + (b[3]))"},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if actual := program.String(); actual != test.expected {
+			t.Errorf("Unexpected program for %q. Expected %q; got %q", test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestMalformedIndexExpressions(t *testing.T) {
+	inputs := []string{
+		"a[",
+		"a[]",
+		"a[1",
+		"a[1:2",
+		"a[1:2:3]",
+		"a[::]",
+		"a[1:2] = 3",
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
+	}
+}
+
+func TestTernaryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a ? b : c", "(a ? b : c)"},
+		{"x > 0 ? x : -x", "((x > 0) ? x : (-x))"},
+		{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
+		{"a ? b ? c : d : e", "(a ? (b ? c : d) : e)"},
+		{"x = a == b ? 1 + 2 : 3 * 4", "(x = ((a == b) ? (1 + 2) : (3 * 4)))"},
+		{"(a ? b : c) + 1", "((a ? b : c) + 1)"},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if actual := program.String(); actual != test.expected {
+			t.Errorf("Unexpected string output. Expected %q; got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestMalformedTernaryExpressions(t *testing.T) {
+	inputs := []string{
+		"a ? b",
+		"a ? b c",
+		"a ? : c",
+		"a ? b :",
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
+	}
+}
+
+func TestSwitchExpression(t *testing.T) {
+	input := `
+		switch (x + 1) {
+		case 1:
+			a;
+			b;
+		case y: c
+		default: d;
+		}
+	`
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Unexpected statement type. Expected *ast.ExpressionStatement; got %T", program.Statements[0])
+	}
+
+	switchExpression, ok := statement.Expression.(*ast.SwitchExpression)
+	if !ok {
+		t.Fatalf("Unexpected expression type. Expected *ast.SwitchExpression; got %T", statement.Expression)
+	}
+
+	if subject := switchExpression.Subject.String(); subject != "(x + 1)" {
+		t.Errorf("Unexpected switch subject. Expected %q; got %q", "(x + 1)", subject)
+	}
+
+	tests := []struct {
+		expectedValue      string
+		expectedDefault    bool
+		expectedStatements int
+	}{
+		{"1", false, 2},
+		{"y", false, 1},
+		{"", true, 1},
+	}
+
+	if count := len(switchExpression.Cases); count != len(tests) {
+		t.Fatalf("Unexpected case count. Expected %d; got %d", len(tests), count)
+	}
+
+	for i, test := range tests {
+		switchCase := switchExpression.Cases[i]
+
+		if switchCase.IsDefault() != test.expectedDefault {
+			t.Errorf("Unexpected default flag for case %d. Expected %t; got %t", i, test.expectedDefault, switchCase.IsDefault())
+		}
+
+		if !test.expectedDefault && switchCase.Value.String() != test.expectedValue {
+			t.Errorf("Unexpected case value. Expected %q; got %q", test.expectedValue, switchCase.Value.String())
+		}
+
+		if count := len(switchCase.Body.Statements); count != test.expectedStatements {
+			t.Errorf("Unexpected statement count for case %d. Expected %d; got %d", i, test.expectedStatements, count)
+		}
+	}
+
+	expectedString := "switch ((x + 1)) {case 1: abcase y: cdefault: d}"
+	if actual := program.String(); actual != expectedString {
+		t.Errorf("Unexpected string output. Expected %q; got %q", expectedString, actual)
+	}
+}
+
+func TestMalformedSwitchExpressions(t *testing.T) {
+	inputs := []string{
+		"switch x { case 1: a }",
+		"switch (x) case 1: a",
+		"switch (x) { case 1 a }",
+		"switch (x) { a; }",
+		"switch (x) { case 1: a",
+		"switch (x) { default: a; default: b; }",
+	}
+
+	for _, input := range inputs {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", input)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	input := "let x 5;"
+
+	parser := New(lexer.New(input))
+	parser.ParseProgram()
+
+	errors := parser.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("Expected parser errors; got none")
+	}
+
+	err := errors[0]
+
+	expectedPosition := token.Position{Line: 1, Column: 7, Offset: 6}
+	if err.Pos != expectedPosition {
+		t.Errorf("Unexpected error position. Expected %s; got %s", expectedPosition, err.Pos)
+	}
+
+	if err.Token.Type != token.INT || err.Token.Literal != "5" {
+		t.Errorf("Unexpected offending token. Expected INT \"5\"; got %s %q", err.Token.Type, err.Token.Literal)
+	}
+
+	if len(err.Expected) != 1 || err.Expected[0] != token.ASSIGN {
+		t.Errorf("Unexpected expected token types. Expected [%s]; got %v", token.ASSIGN, err.Expected)
+	}
+
+	expectedMessage := "1:7: Unexpected next token. Expected next token to be ASSIGN; got INT"
+	if message := err.Error(); message != expectedMessage {
+		t.Errorf("Unexpected error string. Expected %q; got %q", expectedMessage, message)
+	}
+}
+
+func TestSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// Semicolons may be left out before a line break, a closing }, the next case, or the end of input
+		{"let x = 1;", []string{"let x = 1;"}},
+		{"let x = 1", []string{"let x = 1;"}},
+		{"let x = 1\nlet y = 2", []string{"let x = 1;", "let y = 2;"}},
+		{"x; y", []string{"x", "y"}},
+		{"for (i in xs) { i }", []string{"for (i in xs) {i}"}},
+		{"for (i in xs) { i; }", []string{"for (i in xs) {i}"}},
+		{"for (i in xs) { a; b }", []string{"for (i in xs) {ab}"}},
+		{"for (i in xs) {\n\ta\n\tb\n}", []string{"for (i in xs) {ab}"}},
+		{"try { x } catch (e) { return e }", []string{"try {x} catch (e) {return e;}"}},
+		{"switch (x) { case 1: a default: b }", []string{"switch (x) {case 1: adefault: b}"}},
+		// Statements ending with a closing } needn't be separated from the next
+		{"for (i in xs) {} x", []string{"for (i in xs) {}", "x"}},
+		{"let x = switch (y) { default: 1 } z", []string{"let x = switch (y) {default: 1};", "z"}},
+		// Empty statements are left out
+		{";;", []string{}},
+		{"x;;y;", []string{"x", "y"}},
+		{"for (i in xs) { i };", []string{"for (i in xs) {i}"}},
+		// Expressions continue across line breaks, except that ( or [ beginning a line begins a new statement
+		{"let x = 1\n+ 2", []string{"let x = (1 + 2);"}},
+		{"xs\n|> f", []string{"f(xs)"}},
+		{"person\n.name", []string{"(person.name)"}},
+		{"let x = f\n(y)", []string{"let x = f;", "y"}},
+		{"let x = f(\n\ty\n)", []string{"let x = f(y);"}},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+		checkStatementCount(t, program, len(test.expected))
+
+		for i, expected := range test.expected {
+			if actual := program.Statements[i].String(); actual != expected {
+				t.Errorf("Unexpected statement %d of %q. Expected %q; got %q", i, test.input, expected, actual)
+			}
+		}
+	}
+}
+
+func TestMissingSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1 let y = 2", "1:11: Missing semicolon. Expected ; or a line break before LET"},
+		{"x y", "1:3: Missing semicolon. Expected ; or a line break before IDENT"},
+		{"for (i in xs) { a b }", "1:19: Missing semicolon. Expected ; or a line break before IDENT"},
+		{"return 1 2", "1:10: Missing semicolon. Expected ; or a line break before INT"},
+		{"import \"a\" import \"b\"", "1:12: Missing semicolon. Expected ; or a line break before IMPORT"},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("Unexpected error count for %q. Expected 1; got %d: %v", test.input, len(errors), errors)
+		}
+
+		if actual := errors[0].Error(); actual != test.expected {
+			t.Errorf("Unexpected error for %q. Expected %q; got %q", test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestParseWithTrivia(t *testing.T) {
+	input := "#!/usr/bin/env monkey\n/// Doc\nlet x = 1\n\tx |> f\n(y)"
+
+	expected := New(lexer.New(input)).ParseProgram()
+
+	parser := New(lexer.New(input, lexer.WithTrivia()))
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	if !ast.Equal(program, expected) {
+		t.Errorf("Unexpected program. Expected %s; got %s", expected, program)
+	}
+}
+
+func TestMalformedStatementsAreOmitted(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedStatements int
+	}{
+		{"let = 5;", 1}, // The 5 is still parsed as an expression statement after the let fails
+		{"let x 5;", 1},
+		{"let x = ;", 0},
+		{"return +;", 0},
+		{"let = 5; let y = 10;", 2},
+		{"+;", 0},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		program := parser.ParseProgram()
+
+		if len(parser.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %q; got none", test.input)
+		}
+
+		for i, statement := range program.Statements {
+			if statement == nil || statement.TokenLiteral() == ast.NIL_TOKEN_LITERAL {
+				t.Errorf("Statement %d of %q is nil", i, test.input)
+			}
+		}
+
+		if count := len(program.Statements); count != test.expectedStatements {
+			t.Errorf("Unexpected statement count for %q. Expected %d; got %d", test.input, test.expectedStatements, count)
+		}
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "(1 + (2 * 3))"},
+		{"x = y ? 1 : 2;", "(x = (y ? 1 : 2))"},
+		{"a |> f(b)", "f(a, b)"},
+	}
+
+	for _, test := range tests {
+		expression, errors := ParseExpressionString(test.input)
+		if len(errors) > 0 {
+			t.Fatalf("Unexpected errors parsing %q: %v", test.input, errors)
+		}
+
+		if actual := expression.String(); actual != test.expected {
+			t.Errorf("Unexpected expression. Expected %q; got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"", "Failed to find prefix parse function for token EOF"},
+		{"1 + 2 3", "Unexpected token after expression. Expected EOF; got INT"},
+		{"1; 2", "Unexpected token after expression. Expected EOF; got INT"},
+		{"let x = 5;", "Failed to find prefix parse function for token LET"},
+	}
+
+	for _, test := range tests {
+		expression, errors := ParseExpressionString(test.input)
+		if expression != nil {
+			t.Errorf("Unexpected expression for %q. Expected nil; got %q", test.input, expression.String())
+		}
+
+		if len(errors) == 0 {
+			t.Fatalf("Expected parser errors for %q; got none", test.input)
+		}
+
+		if message := errors[0].Message; message != test.expectedMessage {
+			t.Errorf("Unexpected error message. Expected %q; got %q", test.expectedMessage, message)
+		}
+	}
+}
+
+func TestRegisterPrefix(t *testing.T) {
+	parser := New(lexer.New("..5;"))
+	// Parse ..x as 0..x
+	parser.RegisterPrefix(token.DOTDOT, func() ast.Expression {
+		operator := parser.CurrToken()
+		parser.NextToken()
+
+		return &ast.InfixExpression{
+			Token:    operator,
+			Left:     &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "0"}, Value: 0},
+			Operator: operator.Literal,
+			Right:    parser.ParseOperand(RANGE),
+		}
+	})
+
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+	checkStatementCount(t, program, 1)
+
+	if actual := program.String(); actual != "(0 .. 5)" {
+		t.Errorf("Unexpected program. Expected %q; got %q", "(0 .. 5)", actual)
+	}
+}
+
+func TestRegisterInfix(t *testing.T) {
+	parser := New(lexer.New("a : b + c;"))
+	parser.SetPrecedence(token.COLON, SUM)
+	parser.RegisterInfix(token.COLON, func(left ast.Expression) ast.Expression {
+		expression := &ast.InfixExpression{
+			Token:    parser.CurrToken(),
+			Left:     left,
+			Operator: parser.CurrToken().Literal,
+		}
+
+		parser.NextToken()
+		expression.Right = parser.ParseOperand(SUM)
+		return expression
+	})
+
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	if actual := program.String(); actual != "((a : b) + c)" {
+		t.Errorf("Unexpected program. Expected %q; got %q", "((a : b) + c)", actual)
+	}
+
+	// Other parsers keep the default behaviour
+	other := New(lexer.New("a : b;"))
+	other.ParseProgram()
+
+	if len(other.Errors()) == 0 {
+		t.Errorf("Expected parser errors for %q; got none", "a : b;")
+	}
+}
+
+func TestSetPrecedence(t *testing.T) {
+	parser := New(lexer.New("1 + 2 * 3;"))
+	parser.SetPrecedence(token.PLUS, PRODUCT+1)
+
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	if actual := program.String(); actual != "((1 + 2) * 3)" {
+		t.Errorf("Unexpected program. Expected %q; got %q", "((1 + 2) * 3)", actual)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		maxDepth int
+	}{
+		{strings.Repeat("(", 10000), DEFAULT_MAX_DEPTH},
+		{strings.Repeat("!", 10000) + "x;", DEFAULT_MAX_DEPTH},
+		{strings.Repeat("x = ", 10000) + "1;", DEFAULT_MAX_DEPTH},
+		{strings.Repeat("for (x in y) { ", 10000), DEFAULT_MAX_DEPTH},
+		{"((((1))));", 3},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input), WithMaxDepth(test.maxDepth))
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("Unexpected error count. Expected 1; got %d: %v", len(errors), errors)
+		}
+
+		expected := fmt.Sprintf("Nested too deeply. Maximum nesting depth is %d", test.maxDepth)
+		if message := errors[0].Message; message != expected {
+			t.Errorf("Unexpected error message. Expected %q; got %q", expected, message)
+		}
+	}
+}
+
+func TestMaxDepthAllowsNestingWithinLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		maxDepth int
+	}{
+		{"((((1))));", 5},
+		{strings.Repeat("(", 10000) + "1" + strings.Repeat(")", 10000) + ";", 0},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input), WithMaxDepth(test.maxDepth))
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+		checkStatementCount(t, program, 1)
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	input := "let = 1; let = 2; let = 3; let = 4;"
+
+	tests := []struct {
+		maxErrors int
+		expected  []string
+	}{
+		{
+			3,
+			[]string{
+				"1:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN",
+				"1:5: Failed to find prefix parse function for token ASSIGN",
+				"1:14: Unexpected next token. Expected next token to be IDENT; got ASSIGN",
+				"1:14: Too many errors. Stopped after 3",
+			},
+		},
+		{0, nil},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(input), WithMaxErrors(test.maxErrors))
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if test.expected == nil {
+			if len(errors) != 8 {
+				t.Errorf("Unexpected error count. Expected 8; got %d: %v", len(errors), errors)
+			}
+			continue
+		}
+
+		if len(errors) != len(test.expected) {
+			t.Fatalf("Unexpected error count. Expected %d; got %d: %v", len(test.expected), len(errors), errors)
+		}
+
+		for i, expected := range test.expected {
+			if actual := errors[i].Error(); actual != expected {
+				t.Errorf("Unexpected error. Expected %q; got %q", expected, actual)
+			}
+		}
+	}
+}
+
+func TestParsedTreeStructure(t *testing.T) {
+	input := "for (x in 1..n) { total += x * 2; }"
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	x := &ast.Identifier{Value: "x"}
+	total := &ast.Identifier{Value: "total"}
+	expected := &ast.Program{
+		Statements: []ast.Statement{
+			&ast.ForStatement{
+				Variable: x,
+				Iterable: &ast.InfixExpression{Left: &ast.IntegerLiteral{Value: 1}, Operator: "..", Right: &ast.Identifier{Value: "n"}},
+				Body: &ast.BlockStatement{
+					Statements: []ast.Statement{
+						&ast.ExpressionStatement{
+							Expression: &ast.AssignExpression{
+								Target:   total,
+								Operator: "+=",
+								Value:    &ast.InfixExpression{Left: x, Operator: "*", Right: &ast.IntegerLiteral{Value: 2}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if diff := ast.Diff(expected, program); diff != "" {
+		t.Errorf("Unexpected tree. %s", diff)
+	}
+}
+
+func TestStringParsesBack(t *testing.T) {
+	tests := []string{
+		`let s = "say \"hi\" for \$5 or \${x}, \\";`,
+		`"a \"${b}\" c ${d + "e"} \\"`,
+		`let a = person.name; let b = person["name"]; a.b[1:][0]`,
+		`let c = a.b.c += 1; person.age = 5`,
+		`import "lib/util"; let f = x |> g(y); f(1, 2)(3)`,
+		`let m = a < b ? -a : !b | ~c << 2`,
+		`for (i in 0..10) { const j = i == 2 ? i : null; continue; break; }`,
+		`switch (x) { case 1: "one" default: xs[0] }`,
+		`try { risky() } catch (e) { e["k"] }`,
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		reparser := New(lexer.New(program.String()))
+		reparsed := reparser.ParseProgram()
+		if errors := reparser.Errors(); len(errors) > 0 {
+			t.Errorf("Unexpected errors parsing %q back: %v", program.String(), errors)
+			continue
+		}
+
+		if diff := ast.Diff(program, reparsed); diff != "" {
+			t.Errorf("Unexpected tree parsing %q back. %s", program.String(), diff)
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Source spanned by the program's first statement
+	}{
+		{"let x = 5 + y;", "let x = 5 + y"},
+		{"return -x;", "return -x"},
+		{"a.b = c ? d : e", "a.b = c ? d : e"},
+		{"f(1, 2)", "f(1, 2)"},
+		{"x |> f(y)", "x |> f(y)"},
+		{"x |> f", "x |> f"},
+		{"x += 1", "x += 1"},
+		{`"a ${b} c"`, `"a ${b} c"`},
+		{"for (x in xs) { x; }", "for (x in xs) { x; }"},
+		{"switch (x) { case 1: a; default: b }", "switch (x) { case 1: a; default: b }"},
+		{"  a\n  * b", "a\n  * b"},
+	}
+
+	for _, test := range tests {
+		parser := New(lexer.New(test.input))
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		statement := program.Statements[0]
+		if span := test.input[statement.Pos().Offset:statement.End().Offset]; span != test.expected {
+			t.Errorf("Unexpected statement span for %q. Expected %q; got %q", test.input, test.expected, span)
+		}
+	}
+}
+
+func TestNestedNodePositions(t *testing.T) {
+	input := "let total = add(x, 2) * 3;\nreturn person.name;"
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	value := program.Statements[0].(*ast.LetStatement).Value.(*ast.InfixExpression)
+	call := value.Left.(*ast.CallExpression)
+	index := program.Statements[1].(*ast.ReturnStatement).ReturnValue
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart token.Position
+		expectedEnd   token.Position
+	}{
+		{value, token.Position{Line: 1, Column: 13, Offset: 12}, token.Position{Line: 1, Column: 26, Offset: 25}},
+		{call, token.Position{Line: 1, Column: 13, Offset: 12}, token.Position{Line: 1, Column: 22, Offset: 21}},
+		{call.Arguments[1], token.Position{Line: 1, Column: 20, Offset: 19}, token.Position{Line: 1, Column: 21, Offset: 20}},
+		{index, token.Position{Line: 2, Column: 8, Offset: 34}, token.Position{Line: 2, Column: 19, Offset: 45}},
+		{program, token.Position{Line: 1, Column: 1, Offset: 0}, token.Position{Line: 2, Column: 19, Offset: 45}},
+	}
+
+	for _, test := range tests {
+		if start := test.node.Pos(); start != test.expectedStart {
+			t.Errorf("Unexpected start of %q. Expected %+v; got %+v", test.node.String(), test.expectedStart, start)
+		}
+
+		if end := test.node.End(); end != test.expectedEnd {
+			t.Errorf("Unexpected end of %q. Expected %+v; got %+v", test.node.String(), test.expectedEnd, end)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var out strings.Builder
+	parser := New(lexer.New("-x + 1;"), WithTrace(&out))
+	parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	expected := `BEGIN parseExpressionStatement
+	BEGIN parseExpression
+		BEGIN parsePrefixExpression
+			BEGIN parseExpression
+				BEGIN parseIdentifier
+				END parseIdentifier
+			END parseExpression
+		END parsePrefixExpression
+		BEGIN parseInfixExpression
+			BEGIN parseExpression
+				BEGIN parseIntegerLiteral
+				END parseIntegerLiteral
+			END parseExpression
+		END parseInfixExpression
+	END parseExpression
+END parseExpressionStatement
+`
+	if trace := out.String(); trace != expected {
+		t.Errorf("Unexpected trace. Expected %q; got %q", expected, trace)
+	}
+}
+
+// Parsers hold all of their state, so many can run at once without affecting each other. Run with -race
+func TestConcurrentParsers(t *testing.T) {
+	input := "let total = 1 + 2 * 3; for (x in xs) { total += x; } switch (total) { case 1: \"one\" default: \"${total}\" }"
+
+	expectedTrace := func() string {
+		var out strings.Builder
+		New(lexer.New(input), WithTrace(&out)).ParseProgram()
+		return out.String()
+	}()
+	expected := New(lexer.New(input)).ParseProgram().String()
+
+	for i := 0; i < 16; i++ {
+		t.Run(fmt.Sprintf("parser %d", i), func(t *testing.T) {
+			t.Parallel()
+
+			for j := 0; j < 50; j++ {
+				// Alternate parsers trace or set their own precedence, which mustn't leak into the others
+				var trace strings.Builder
+				var parser *Parser
+				if i%2 == 0 {
+					parser = New(lexer.New(input), WithTrace(&trace))
+				} else {
+					parser = New(lexer.New(input))
+					parser.SetPrecedence(token.PLUS, PRODUCT+1)
+				}
+
+				program := parser.ParseProgram()
+				checkParserErrors(t, parser)
+
+				if i%2 == 1 {
+					if actual := program.Statements[0].String(); actual != "let total = ((1 + 2) * 3);" {
+						t.Fatalf("Unexpected statement. Expected %q; got %q", "let total = ((1 + 2) * 3);", actual)
+					}
+					continue
+				}
+
+				if actual := program.String(); actual != expected {
+					t.Fatalf("Unexpected program. Expected %q; got %q", expected, actual)
+				}
+				if actual := trace.String(); actual != expectedTrace {
+					t.Fatalf("Unexpected trace. Expected %q; got %q", expectedTrace, actual)
+				}
+			}
+		})
+	}
+}
+
+func testLetStatement(t *testing.T, statement ast.Statement, identifier string) bool {
+	if statement.TokenLiteral() != "let" {
+		t.Errorf("Unexpected token literal. Expected \"let\". Got %q", statement.TokenLiteral())
+		return false
+	}
+
+	letStatement, ok := statement.(*ast.LetStatement)
+	if !ok {
+		t.Errorf("Unexpected statement type. Expected *ast.LetStatement. Got %T", statement)
+		return false
+	}
+
+	if name := letStatement.Name.Value; name != identifier {
+		t.Errorf("Unexpected let statement name. Expected %q. Got %q", identifier, name)
+		return false
+	}
+
+	if literal := letStatement.Name.TokenLiteral(); literal != identifier {
+		t.Errorf("Unexpected let statement token literal. Expected %q. Got %q", identifier, literal)
+		return false
+	}
+
+	return true
+}
+
+func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
+	integerLiteral, ok := il.(*ast.IntegerLiteral)
+	if !ok {
+		t.Errorf("Unexpected expression type. Expected *ast.IntegerLiteral; got %T", il)
+		return false
+	}
+
+	if literalValue := integerLiteral.Value; literalValue != value {
+		t.Errorf("Unexpected literal value. Expected %d; got %d", value, literalValue)
+		return false
+	}
+
+	if tokenLiteral := integerLiteral.TokenLiteral(); tokenLiteral != fmt.Sprintf("%d", value) {
+		t.Errorf("Unexpected token literal. Expected %d; got %q", value, tokenLiteral)
+		return false
+	}
+
+	return true
+}
+
+func checkParserErrors(t *testing.T, p *Parser) {
+	errors := p.Errors()
+
+	if len(errors) == 0 {
+		return
+	}
+
+	t.Errorf("Parser has %d error(s)", len(errors))
+
+	for _, err := range errors {
+		t.Errorf("Parser error: %q", err.Error())
+	}
 
 	t.FailNow()
 }
@@ -340,3 +1979,62 @@ func checkStatementCount(t *testing.T, program *ast.Program, expectedCount int)
 		t.Fatalf("Unexpected statement count. Expected %d statement(s); got %d", expectedCount, numStatements)
 	}
 }
+
+// Read the program shared by the lexer and parser benchmarks, repeated to several megabytes. It exercises most
+// kinds of token, expression, and statement
+func largeProgram(b *testing.B) string {
+	source, err := os.ReadFile(filepath.Join("..", "testdata", "large.monkey"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return strings.Repeat(string(source), 20000)
+}
+
+func BenchmarkParseLargeProgram(b *testing.B) {
+	input := largeProgram(b)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+
+		if errors := parser.Errors(); len(errors) > 0 {
+			b.Fatalf("Parser errors: %v", errors)
+		}
+	}
+}
+
+func FuzzParseProgram(f *testing.F) {
+	for _, seed := range []string{
+		"let x = 5; const y = x * (2 + 3);",
+		"for (i in 1..10) { total += i; }",
+		`switch (x) { case 1: "one" default: "${x}" }`,
+		"x |> f(y) |> g; a ? b : c; person.name = ~1 << 2;",
+		"import \"lib\"; try { f() } catch (e) { g(e) }",
+		"((((((((((",
+		"let = ; } ) ]",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		parser := New(lexer.New(input))
+		program := parser.ParseProgram()
+
+		if program == nil {
+			t.Fatalf("ParseProgram returned nil for %q", input)
+		}
+
+		// Even a program that failed to parse must be printable, e.g., for error messages
+		_ = program.String()
+		program.End()
+
+		for _, err := range parser.Errors() {
+			if err.Pos.Offset < 0 || err.Pos.Offset > len(input) {
+				t.Fatalf("Parser error outside the input: %v", err)
+			}
+		}
+	})
+}
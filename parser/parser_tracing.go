@@ -2,34 +2,40 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"strings"
 )
 
-var traceLevel = 0
-
-func padIdent() string {
-	return strings.Repeat("\t", traceLevel-1)
+// Write an indented BEGIN/END line to w each time the parser enters or exits a parse function,
+// showing how the parser recursed through the input
+func WithTrace(w io.Writer) Option {
+	return func(p *Parser) {
+		p.traceOut = w
+	}
 }
 
-func tracePrint(fnName string) {
-	fmt.Printf("%s%s\n", padIdent(), fnName)
+func (p *Parser) tracePrint(message string) {
+	fmt.Fprintf(p.traceOut, "%s%s\n", strings.Repeat("\t", p.traceLevel-1), message)
 }
 
-func incIdent() {
-	traceLevel += 1
-}
+// Record entering the named parse function. Returns the name to pass to untrace, e.g.,
+// 'defer p.untrace(p.trace("parseExpression"))'. Does nothing unless tracing was enabled
+func (p *Parser) trace(fnName string) string {
+	if p.traceOut == nil {
+		return fnName
+	}
 
-func decIdent() {
-	traceLevel -= 1
+	p.traceLevel += 1
+	p.tracePrint("BEGIN " + fnName)
+	return fnName
 }
 
-func trace(message string) string {
-	incIdent()
-	tracePrint("BEGIN " + message)
-	return message
-}
+// Record exiting the named parse function
+func (p *Parser) untrace(fnName string) {
+	if p.traceOut == nil {
+		return
+	}
 
-func untrace(message string) {
-	tracePrint("END " + message)
-	decIdent()
+	p.tracePrint("END " + fnName)
+	p.traceLevel -= 1
 }
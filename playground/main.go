@@ -0,0 +1,88 @@
+//go:build js && wasm
+
+// Command playground exposes the interpreter to JavaScript when built for WebAssembly, e.g., to power a
+// browser playground:
+//
+//	GOOS=js GOARCH=wasm go build -o monkey.wasm ./playground
+//
+// Once loaded with wasm_exec.js, the page can call monkey.parse(source) and monkey.eval(source). Each returns
+// an object rather than writing to stdout: parse's holds the formatted program, its syntax tree, and any
+// errors; eval's holds the output and any errors. Errors have a message and the line and column each side of
+// the offending token
+package main
+
+import (
+	"bytes"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/ast/printer"
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+	"syscall/js"
+)
+
+func main() {
+	js.Global().Set("monkey", js.ValueOf(map[string]any{
+		"parse": js.FuncOf(parse),
+		"eval":  js.FuncOf(eval),
+	}))
+
+	// Keep serving calls from JavaScript
+	select {}
+}
+
+// Parse the source given as the first argument, returning {program, ast, errors}. program and ast are null if
+// there are errors
+func parse(this js.Value, args []js.Value) any {
+	program, errors := parseSource(args)
+	if len(errors) > 0 {
+		return map[string]any{"program": nil, "ast": nil, "errors": errors}
+	}
+
+	var tree bytes.Buffer
+	if err := ast.WriteJSON(&tree, program); err != nil {
+		return map[string]any{"program": nil, "ast": nil, "errors": []any{map[string]any{"message": err.Error()}}}
+	}
+
+	return map[string]any{
+		"program": printer.String(program),
+		"ast":     js.Global().Get("JSON").Call("parse", tree.String()),
+		"errors":  []any{},
+	}
+}
+
+// Run the source given as the first argument, returning {output, errors}
+func eval(this js.Value, args []js.Value) any {
+	_, errors := parseSource(args)
+	if len(errors) > 0 {
+		return map[string]any{"output": "", "errors": errors}
+	}
+
+	// TODO: Evaluate the program once the evaluator exists
+	return map[string]any{
+		"output": "",
+		"errors": []any{map[string]any{"message": "Evaluation isn't available until the evaluator exists"}},
+	}
+}
+
+// Parse the source given as the first argument, converting any errors to JavaScript objects
+func parseSource(args []js.Value) (*ast.Program, []any) {
+	if len(args) == 0 || args[0].Type() != js.TypeString {
+		return nil, []any{map[string]any{"message": "Expected source as a string"}}
+	}
+
+	p := parser.New(lexer.New(args[0].String()))
+	program := p.ParseProgram()
+
+	errors := []any{}
+	for _, err := range p.Errors() {
+		errors = append(errors, map[string]any{
+			"message":   err.Message,
+			"line":      err.Token.Pos.Line,
+			"column":    err.Token.Pos.Column,
+			"endLine":   err.Token.End.Line,
+			"endColumn": err.Token.End.Column,
+		})
+	}
+
+	return program, errors
+}
@@ -0,0 +1,41 @@
+package repl
+
+import "rowanlovejoy/monkey/token"
+
+// ANSI escape sequences used to colour REPL output
+const (
+	RESET   = "\x1b[0m"
+	RED     = "\x1b[31m"
+	GREEN   = "\x1b[32m"
+	MAGENTA = "\x1b[35m"
+	CYAN    = "\x1b[36m"
+)
+
+// Get the colour used to highlight a token, or an empty string if it's left uncoloured
+func tokenColor(tok token.Token) string {
+	switch tok.Type {
+	case token.ILLEGAL:
+		return RED
+	case token.INT, token.TRUE, token.FALSE, token.NULL:
+		return CYAN
+	case token.STRING, token.STRINGHEAD, token.STRINGMIDDLE, token.STRINGTAIL:
+		return GREEN
+	case token.IDENT:
+		return ""
+	}
+
+	// Keywords are the tokens whose literal is looked up as something other than an identifier
+	if token.LookupIdent(tok.Literal) == tok.Type {
+		return MAGENTA
+	}
+
+	return ""
+}
+
+// Wrap s in the given colour, if any
+func colorize(s string, color string) string {
+	if color == "" {
+		return s
+	}
+	return color + s + RESET
+}
@@ -0,0 +1,100 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"rowanlovejoy/monkey/diagnostic"
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+	"strings"
+)
+
+// Lines beginning with this are commands to the REPL itself rather than Monkey source, e.g., ':reset'
+const COMMAND_PREFIX = ":"
+
+// Run a REPL command, given without its prefix
+func (r *repl) runCommand(command string) {
+	name, argument, _ := strings.Cut(strings.TrimSpace(command), " ")
+	argument = strings.TrimSpace(argument)
+
+	switch name {
+	case "mode":
+		if argument == "" {
+			fmt.Fprintf(r.out, "Mode %s\n", r.mode)
+			return
+		}
+
+		if err := CheckMode(Mode(argument)); err != nil {
+			r.printCommandError(err.Error())
+			return
+		}
+
+		r.mode = Mode(argument)
+		fmt.Fprintf(r.out, "Mode %s\n", r.mode)
+	case "save":
+		if argument == "" {
+			r.printCommandError(fmt.Sprintf("Usage: %ssave <file>", COMMAND_PREFIX))
+			return
+		}
+
+		if err := r.save(argument); err != nil {
+			r.printCommandError(err.Error())
+			return
+		}
+
+		fmt.Fprintf(r.out, "Session saved to %s\n", argument)
+	case "load":
+		if argument == "" {
+			r.printCommandError(fmt.Sprintf("Usage: %sload <file>", COMMAND_PREFIX))
+			return
+		}
+
+		r.load(argument)
+	case "reset":
+		r.reset()
+		fmt.Fprintln(r.out, "Session reset")
+	default:
+		r.printCommandError(fmt.Sprintf("Unknown command %s%s", COMMAND_PREFIX, name))
+	}
+}
+
+// Write the statements of the session to path, one line each, so that the file can be loaded or run later
+func (r *repl) save(path string) error {
+	var source strings.Builder
+	for _, statement := range r.statements {
+		source.WriteString(statement)
+		source.WriteString("\n")
+	}
+
+	return os.WriteFile(path, []byte(source.String()), 0644)
+}
+
+// Replay the statements in the file at path into the session. Nothing is loaded if the file fails to parse
+func (r *repl) load(path string) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		r.printCommandError(err.Error())
+		return
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+
+	if errors := p.Errors(); len(errors) > 0 {
+		for _, d := range diagnostic.FromParseErrors(errors) {
+			r.printDiagnostic(path, string(source), d)
+		}
+		return
+	}
+
+	r.completer.observe(string(source))
+	if len(program.Statements) > 0 {
+		r.statements = append(r.statements, strings.TrimRight(string(source), "\n"))
+	}
+
+	fmt.Fprintf(r.out, "Loaded %d statements from %s\n", len(program.Statements), path)
+}
+
+func (r *repl) printCommandError(message string) {
+	fmt.Fprintln(r.out, colorize(message, r.errorColor()))
+}
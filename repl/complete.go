@@ -0,0 +1,61 @@
+package repl
+
+import (
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/token"
+	"sort"
+	"strings"
+)
+
+// Completes the word being typed in the REPL from keywords and the names the session has bound
+type completer struct {
+	names map[string]bool // Names bound by let or const statements entered so far
+}
+
+func newCompleter() *completer {
+	return &completer{names: make(map[string]bool)}
+}
+
+// Record the names bound by any let or const statements in line
+func (c *completer) observe(line string) {
+	tokens := lexer.Tokenize(line)
+
+	for i := 1; i < len(tokens); i++ {
+		if tokens[i].Type == token.IDENT && (tokens[i-1].Type == token.LET || tokens[i-1].Type == token.CONST) {
+			c.names[tokens[i].Literal] = true
+		}
+	}
+}
+
+// List the completions, in alphabetical order, of the word ending at cursor in line
+func (c *completer) complete(line string, cursor int) []string {
+	start := cursor
+	for start > 0 && isWordChar(line[start-1]) {
+		start -= 1
+	}
+
+	prefix := line[start:cursor]
+	if prefix == "" {
+		return nil
+	}
+
+	var completions []string
+	for _, keyword := range token.Keywords() {
+		if strings.HasPrefix(keyword, prefix) {
+			completions = append(completions, keyword)
+		}
+	}
+	for name := range c.names {
+		if strings.HasPrefix(name, prefix) && token.LookupIdent(name) == token.IDENT {
+			completions = append(completions, name)
+		}
+	}
+
+	sort.Strings(completions)
+	return completions
+}
+
+// Report whether ch can be part of an identifier or keyword
+func isWordChar(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
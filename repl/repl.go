@@ -4,24 +4,246 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"rowanlovejoy/monkey/ast"
+	"rowanlovejoy/monkey/ast/printer"
+	"rowanlovejoy/monkey/diagnostic"
 	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
 	"rowanlovejoy/monkey/token"
+	"strings"
 )
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+const PROMPT = ">>"
+
+// Prompt shown while reading the rest of input that continues over several lines, e.g., an unclosed block
+const CONTINUATION_PROMPT = ".."
+
+// Pipeline stage whose output the REPL shows for each line
+type Mode string
+
+const (
+	LEX_MODE   Mode = "lex"   // Print each token
+	PARSE_MODE Mode = "parse" // Print the parsed program as formatted source
+	JSON_MODE  Mode = "json"  // Print the parsed program's syntax tree as JSON
+	EVAL_MODE  Mode = "eval"  // Print the value each line evaluates to
+)
+
+// Report whether the REPL supports a mode, returning an error explaining why not if it doesn't
+func CheckMode(mode Mode) error {
+	switch mode {
+	case LEX_MODE, PARSE_MODE, JSON_MODE:
+		return nil
+	case EVAL_MODE:
+		return fmt.Errorf("Mode %s isn't available until the evaluator exists", mode)
+	default:
+		return fmt.Errorf("Unknown mode %s. Expected %s, %s, %s, or %s", mode, LEX_MODE, PARSE_MODE, JSON_MODE, EVAL_MODE)
+	}
+}
+
+// Start in the given mode rather than LEX_MODE. The mode must pass CheckMode
+func WithMode(mode Mode) Option {
+	return func(r *repl) {
+		r.mode = mode
+	}
+}
+
+// Configures optional REPL behaviour when passed to Start
+type Option func(*repl)
+
+// Highlight output with ANSI colours: tokens by kind and errors in red. Only suitable when writing to a terminal
+func WithColor() Option {
+	return func(r *repl) {
+		r.color = true
+	}
+}
+
+// Abandon the current line on each interrupt received from interrupts, e.g., os.Interrupt from Ctrl-C,
+// rather than letting it end the process. A second interrupt in a row ends the session
+func WithInterrupts(interrupts <-chan os.Signal) Option {
+	return func(r *repl) {
+		r.interrupts = interrupts
+	}
+}
+
+type repl struct {
+	out        io.Writer
+	mode       Mode             // Pipeline stage whose output is shown
+	color      bool             // Whether to highlight output with ANSI colours
+	completer  *completer       // Completes names bound during the session
+	statements []string         // Source of each line entered or loaded that parsed successfully, in order
+	pending    []string         // Lines of input entered so far that end before it's complete
+	interrupts <-chan os.Signal // Interrupts to handle; nil if they're left to end the process
+}
+
+// Discard everything the session has bound, as if it had just started
+func (r *repl) reset() {
+	r.completer = newCompleter()
+	r.statements = nil
+}
+
+func Start(in io.Reader, out io.Writer, options ...Option) {
+	r := &repl{out: out, mode: LEX_MODE}
+	r.reset()
+
+	for _, option := range options {
+		option(r)
+	}
+
+	// Lines are read in the background so that an interrupt can be handled while waiting for one
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	interrupted := false
 
 	for {
-		fmt.Fprint(out, ">>")
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		if len(r.pending) > 0 {
+			fmt.Fprint(out, CONTINUATION_PROMPT)
+		} else {
+			fmt.Fprint(out, PROMPT)
 		}
-		line := scanner.Text()
-		l := lexer.New(line)
 
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Fprintf(out, "%v\n", tok)
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			interrupted = false
+			r.handle(line)
+		case <-r.interrupts:
+			// A second interrupt in a row ends the session
+			if interrupted {
+				fmt.Fprintln(out)
+				return
+			}
+			interrupted = true
+			r.pending = nil
+			fmt.Fprintln(out, "\n(To exit, press Ctrl-C again or Ctrl-D)")
 		}
 	}
 }
+
+// Run a line entered at the prompt. Input that ends before it's complete, e.g., with a block left open, is
+// held until a later line completes it or a blank line gives up on it
+func (r *repl) handle(line string) {
+	if len(r.pending) == 0 && strings.HasPrefix(line, COMMAND_PREFIX) {
+		r.runCommand(strings.TrimPrefix(line, COMMAND_PREFIX))
+		return
+	}
+
+	// Without a line editor Tab can't be intercepted as it's pressed, so a line ending in one asks for completions
+	if len(r.pending) == 0 && strings.HasSuffix(line, "\t") {
+		r.printCompletions(strings.TrimSuffix(line, "\t"))
+		return
+	}
+
+	if len(r.pending) > 0 {
+		line = strings.Join(append(r.pending, line), "\n")
+	}
+
+	p := parser.New(lexer.New(line))
+	program := p.ParseProgram()
+	errors := p.Errors()
+
+	if incomplete(errors) && !strings.HasSuffix(line, "\n") {
+		r.pending = strings.Split(line, "\n")
+		return
+	}
+	r.pending = nil
+
+	line = strings.TrimRight(line, "\n")
+	r.completer.observe(line)
+
+	if len(errors) == 0 && len(program.Statements) > 0 {
+		r.statements = append(r.statements, line)
+	}
+
+	switch r.mode {
+	case LEX_MODE:
+		r.lex(line)
+	case PARSE_MODE, JSON_MODE:
+		r.printProgram(line, program, errors)
+	}
+}
+
+// Report whether errors show input ended before it was complete, rather than being malformed
+func incomplete(errors []*parser.ParseError) bool {
+	for _, err := range errors {
+		if err.Token.Type == token.EOF {
+			return true
+		}
+	}
+	return false
+}
+
+// Print each token in line, followed by an error locating any illegal tokens
+func (r *repl) lex(line string) {
+	var illegal []token.Token
+
+	for _, tok := range lexer.Tokenize(line) {
+		if tok.Type == token.ILLEGAL {
+			illegal = append(illegal, tok)
+		}
+
+		if r.color {
+			fmt.Fprintf(r.out, "{%s %s %s %s}\n", colorize(string(tok.Type), tokenColor(tok)), tok.Literal, tok.Pos, tok.End)
+		} else {
+			fmt.Fprintf(r.out, "%v\n", tok)
+		}
+	}
+
+	for _, tok := range illegal {
+		r.printDiagnostic("", line, diagnostic.Diagnostic{Pos: tok.Pos, End: tok.End, Message: fmt.Sprintf("Illegal token %q", tok.Literal)})
+	}
+}
+
+// Print the program parsed from line as formatted source or, in JSON_MODE, its syntax tree as JSON, or errors locating why it failed to parse
+func (r *repl) printProgram(line string, program *ast.Program, errors []*parser.ParseError) {
+	if len(errors) > 0 {
+		for _, d := range diagnostic.FromParseErrors(errors) {
+			r.printDiagnostic("", line, d)
+		}
+		return
+	}
+
+	if r.mode == JSON_MODE {
+		ast.WriteJSON(r.out, program)
+		return
+	}
+
+	fmt.Fprint(r.out, printer.String(program))
+}
+
+// List the completions of the word at the end of line
+func (r *repl) printCompletions(line string) {
+	completions := r.completer.complete(line, len(line))
+	if len(completions) == 0 {
+		return
+	}
+
+	fmt.Fprintln(r.out, strings.Join(completions, " "))
+}
+
+// Print d, marking its span within the line of source it came from. Name is the file the source was read from, if any
+func (r *repl) printDiagnostic(name string, source string, d diagnostic.Diagnostic) {
+	header := d.Error()
+	if name != "" {
+		header = name + ":" + header
+	}
+
+	fmt.Fprint(r.out, colorize(header, r.errorColor())+"\n")
+	fmt.Fprint(r.out, diagnostic.Snippet(source, d.Pos, d.End))
+}
+
+func (r *repl) errorColor() string {
+	if r.color {
+		return RED
+	}
+	return ""
+}
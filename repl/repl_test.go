@@ -0,0 +1,272 @@
+package repl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	in := strings.NewReader("let x = @;\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	expected := PROMPT +
+		"{LET let 1:1 1:4}\n" +
+		"{IDENT x 1:5 1:6}\n" +
+		"{ASSIGN = 1:7 1:8}\n" +
+		"{ILLEGAL @ 1:9 1:10}\n" +
+		"{SEMICOLON ; 1:10 1:11}\n" +
+		"1:9: Illegal token \"@\"\n" +
+		"let x = @;\n" +
+		"        ^\n" +
+		PROMPT
+
+	if actual := out.String(); actual != expected {
+		t.Errorf("Unexpected output. Expected %q; got %q", expected, actual)
+	}
+}
+
+func TestStartWithColor(t *testing.T) {
+	in := strings.NewReader(`let s = "a"; @` + "\n")
+	var out bytes.Buffer
+
+	Start(in, &out, WithColor())
+
+	expectedLines := []string{
+		"{" + MAGENTA + "LET" + RESET + " let 1:1 1:4}",
+		"{IDENT s 1:5 1:6}",
+		"{" + GREEN + "STRING" + RESET + " a 1:9 1:12}",
+		"{" + RED + "ILLEGAL" + RESET + " @ 1:14 1:15}",
+		RED + "1:14: Illegal token \"@\"" + RESET,
+	}
+
+	for _, line := range expectedLines {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Expected output to contain line %q; got %q", line, out.String())
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	c := newCompleter()
+	c.observe("let total = 1; const tolerance = 2; let x = totally;")
+
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"to", []string{"tolerance", "total"}},
+		{"x + tot", []string{"total"}},
+		{"re", []string{"return"}},
+		{"c", []string{"case", "catch", "const", "continue"}},
+		{"let y = ", nil},
+		{"zzz", nil},
+	}
+
+	for _, test := range tests {
+		actual := c.complete(test.line, len(test.line))
+		if strings.Join(actual, " ") != strings.Join(test.expected, " ") {
+			t.Errorf("Unexpected completions for %q. Expected %q; got %q", test.line, test.expected, actual)
+		}
+	}
+}
+
+func TestStartCompletion(t *testing.T) {
+	in := strings.NewReader("let counter = 0;\ncou\t\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	if !strings.HasSuffix(out.String(), PROMPT+"counter\n"+PROMPT) {
+		t.Errorf("Expected completions of %q after the prompt; got %q", "cou", out.String())
+	}
+}
+
+func TestReset(t *testing.T) {
+	in := strings.NewReader("let counter = 0;\n:reset\ncou\t\n:bogus\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	if strings.Contains(out.String(), "counter\n") {
+		t.Errorf("Expected names bound before :reset to be forgotten; got %q", out.String())
+	}
+
+	for _, expected := range []string{PROMPT + "Session reset\n", PROMPT + "Unknown command :bogus\n"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %q; got %q", expected, out.String())
+		}
+	}
+}
+
+func TestInterrupts(t *testing.T) {
+	// Input that never arrives, so only the interrupts are handled
+	in, writer := io.Pipe()
+	defer writer.Close()
+
+	interrupts := make(chan os.Signal)
+	var out bytes.Buffer
+	done := make(chan bool)
+
+	go func() {
+		Start(in, &out, WithInterrupts(interrupts))
+		done <- true
+	}()
+
+	interrupts <- os.Interrupt
+	interrupts <- os.Interrupt
+	<-done
+
+	expected := PROMPT + "\n(To exit, press Ctrl-C again or Ctrl-D)\n" + PROMPT + "\n"
+	if actual := out.String(); actual != expected {
+		t.Errorf("Unexpected output. Expected %q; got %q", expected, actual)
+	}
+}
+
+func TestModes(t *testing.T) {
+	in := strings.NewReader(":mode parse\nlet x = 1+2\nlet = 3\n:mode eval\n:mode bogus\n:mode\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	for _, expected := range []string{
+		PROMPT + "Mode parse\n",
+		PROMPT + "let x = 1 + 2;\n",
+		PROMPT + "1:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\nlet = 3\n    ^\n",
+		PROMPT + "Mode eval isn't available until the evaluator exists\n",
+		PROMPT + "Unknown mode bogus. Expected lex, parse, json, or eval\n",
+		PROMPT + "Mode parse\n" + PROMPT,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %q; got %q", expected, out.String())
+		}
+	}
+}
+
+func TestStartWithMode(t *testing.T) {
+	in := strings.NewReader("let x = 1+2\n")
+	var out bytes.Buffer
+
+	Start(in, &out, WithMode(PARSE_MODE))
+
+	expected := PROMPT + "let x = 1 + 2;\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("Unexpected output. Expected %q; got %q", expected, out.String())
+	}
+}
+
+func TestContinuation(t *testing.T) {
+	in := strings.NewReader("for (i in xs) {\n\ti\n}\nlet x =\n\n:mode\n")
+	var out bytes.Buffer
+
+	Start(in, &out, WithMode(PARSE_MODE))
+
+	expected := PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + "for (i in xs) {\n\ti;\n}\n" +
+		// A blank line gives up on incomplete input, reporting why it failed to parse
+		PROMPT + CONTINUATION_PROMPT + "2:1: Failed to find prefix parse function for token EOF\nlet x =\n       ^\n" +
+		PROMPT + "Mode parse\n" + PROMPT
+	if actual := out.String(); actual != expected {
+		t.Errorf("Unexpected output. Expected %q; got %q", expected, actual)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.monkey")
+
+	in := strings.NewReader("let counter = 0;\nlet = 1;\n:mode parse\nlet total = counter + 1;\n:save " + path + "\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read saved session: %s", err)
+	}
+
+	// The statement that failed to parse isn't saved
+	expected := "let counter = 0;\nlet total = counter + 1;\n"
+	if string(saved) != expected {
+		t.Errorf("Unexpected saved session. Expected %q; got %q", expected, string(saved))
+	}
+
+	in = strings.NewReader(":load " + path + "\ntot\t\n")
+	out.Reset()
+
+	Start(in, &out)
+
+	expected = PROMPT + "Loaded 2 statements from " + path + "\n" + PROMPT + "total\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("Unexpected output. Expected %q; got %q", expected, out.String())
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.monkey")
+	if err := os.WriteFile(path, []byte("let counter = 0;\nlet = 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	in := strings.NewReader(":load " + path + "\ncou\t\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	expected := path + ":2:5: Unexpected next token. Expected next token to be IDENT; got ASSIGN\n"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("Expected output to contain %q; got %q", expected, out.String())
+	}
+
+	if strings.Contains(out.String(), "counter") {
+		t.Errorf("Expected nothing to be loaded from a file that fails to parse; got %q", out.String())
+	}
+}
+
+func TestJSONMode(t *testing.T) {
+	in := strings.NewReader("x\n")
+	var out bytes.Buffer
+
+	Start(in, &out, WithMode(JSON_MODE))
+
+	output := strings.TrimSuffix(strings.TrimPrefix(out.String(), PROMPT), PROMPT)
+
+	var tree map[string]any
+	if err := json.Unmarshal([]byte(output), &tree); err != nil {
+		t.Fatalf("Expected JSON output; got %q: %v", output, err)
+	}
+
+	if tree["type"] != "Program" {
+		t.Errorf("Unexpected node type. Expected %q; got %q", "Program", tree["type"])
+	}
+}
+
+// Sessions share no state, so many can run at once, each in its own mode. Run with -race
+func TestConcurrentSessions(t *testing.T) {
+	input := "let x = 1+2\nfor (i in xs) {\n\ti\n}\n:mode\nlet = 3\n"
+
+	for _, mode := range []Mode{LEX_MODE, PARSE_MODE, JSON_MODE} {
+		var expected bytes.Buffer
+		Start(strings.NewReader(input), &expected, WithMode(mode))
+
+		for i := 0; i < 8; i++ {
+			t.Run(fmt.Sprintf("%s %d", mode, i), func(t *testing.T) {
+				t.Parallel()
+
+				var out bytes.Buffer
+				Start(strings.NewReader(input), &out, WithMode(mode), WithColor())
+				Start(strings.NewReader(input), &out, WithMode(mode))
+
+				// The uncoloured session must be unaffected by the coloured one before it
+				if actual := out.String(); !strings.HasSuffix(actual, expected.String()) {
+					t.Errorf("Unexpected output. Expected it to end with %q; got %q", expected.String(), actual)
+				}
+			})
+		}
+	}
+}
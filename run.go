@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"rowanlovejoy/monkey/lexer"
+	"rowanlovejoy/monkey/parser"
+)
+
+// Read, parse, and execute the script at path, returning the process exit code
+func runFile(path string, out io.Writer, errOut io.Writer) int {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(errOut, "%s\n", err)
+		return 1
+	}
+
+	return runSource(path, string(source), out, errOut)
+}
+
+// Read a script from r, e.g., piped to standard input, then parse and execute it as runSource does
+func runReader(name string, r io.Reader, out io.Writer, errOut io.Writer) int {
+	source, err := io.ReadAll(r)
+	if err != nil {
+		fmt.Fprintf(errOut, "%s: %s\n", name, err)
+		return 1
+	}
+
+	return runSource(name, string(source), out, errOut)
+}
+
+// Parse and execute a script, naming it in errors as name. Returns the process exit code, which is 1 until the
+// evaluator exists
+func runSource(name string, source string, out io.Writer, errOut io.Writer) int {
+	p := parser.New(lexer.New(source))
+	p.ParseProgram()
+
+	if errors := p.Errors(); len(errors) > 0 {
+		printParseErrors(errOut, name, source, errors)
+		return 1
+	}
+
+	// TODO: Evaluate the program, writing its output to out, once the evaluator exists
+	fmt.Fprintf(errOut, "%s: Evaluation isn't available until the evaluator exists\n", name)
+
+	return 1
+}
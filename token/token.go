@@ -1,10 +1,28 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // Position of the token's first char in the source
+	End     Position // Position immediately after the token's last char
+}
+
+// A location in the source. Lines and columns are counted from 1
+type Position struct {
+	Line   int
+	Column int
+	Offset int // Bytes from the start of the source, counted from 0
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
@@ -13,8 +31,22 @@ const (
 	EOF     = "EOF"     // End of file
 
 	// Identifiers and literals
-	IDENT = "IDENT" // E.g., add, foobar, x, y
-	INT   = "INT"   // E.g., 3, 5
+	IDENT  = "IDENT"  // E.g., add, foobar, x, y
+	INT    = "INT"    // E.g., 3, 5
+	STRING = "STRING" // E.g., "foo"
+
+	// Documentation for the declaration that follows, one token per line
+	DOCCOMMENT = "DOCCOMMENT" // E.g., /// Adds two numbers
+
+	// Trivia, only produced by lexers created with lexer.WithTrivia
+	WHITESPACE = "WHITESPACE" // E.g., spaces, tabs, and line breaks between tokens
+	SHEBANG    = "SHEBANG"    // E.g., #!/usr/bin/env monkey
+
+	// Pieces of an interpolated string, e.g., "a ${x} b ${y} c" is lexed as STRINGHEAD, the tokens of x,
+	// STRINGMIDDLE, the tokens of y, then STRINGTAIL
+	STRINGHEAD   = "STRINGHEAD"   // E.g., "a ${
+	STRINGMIDDLE = "STRINGMIDDLE" // E.g., } b ${
+	STRINGTAIL   = "STRINGTAIL"   // E.g., } c"
 
 	// Operators
 	ASSIGN   = "ASSIGN"   // =
@@ -27,6 +59,22 @@ const (
 	GT       = "GT"       // AKA greater than, >
 	EQ       = "EQ"       // ==
 	NOTEQ    = "NOTEQ"    // !=
+	DOTDOT   = "DOTDOT"   // ..
+	PIPE     = "PIPE"     // |>
+
+	// Bitwise operators
+	AMPERSAND = "AMPERSAND" // &
+	BAR       = "BAR"       // |
+	CARET     = "CARET"     // ^
+	TILDE     = "TILDE"     // ~
+	LTLT      = "LTLT"      // AKA left shift, <<
+	GTGT      = "GTGT"      // AKA right shift, >>
+
+	// Compound assignment operators
+	PLUSASSIGN     = "PLUSASSIGN"     // +=
+	MINUSASSIGN    = "MINUSASSIGN"    // -=
+	ASTERISKASSIGN = "ASTERISKASSIGN" // *=
+	SLASHASSIGN    = "SLASHASSIGN"    // /=
 
 	// Delimiters
 	COMMA     = "COMMA"     // ,
@@ -35,6 +83,11 @@ const (
 	RPAREN    = "RPAREN"    // )
 	LBRACE    = "LBRACE"    // {
 	RBRACE    = "RBRACE"    // }
+	LBRACKET  = "LBRACKET"  // [
+	RBRACKET  = "RBRACKET"  // ]
+	QUESTION  = "QUESTION"  // ?
+	COLON     = "COLON"     // :
+	DOT       = "DOT"       // .
 
 	// Keywords
 	FUNCTION = "FUNCTION" // fn
@@ -44,26 +97,70 @@ const (
 	IF       = "IF"       // if
 	ELSE     = "ELSE"     // else
 	RETURN   = "RETURN"   // return
+	FOR      = "FOR"      // for
+	IN       = "IN"       // in
+	NULL     = "NULL"     // null
+	CONST    = "CONST"    // const
+	SWITCH   = "SWITCH"   // switch
+	CASE     = "CASE"     // case
+	DEFAULT  = "DEFAULT"  // default
+	IMPORT   = "IMPORT"   // import
+	TRY      = "TRY"      // try
+	CATCH    = "CATCH"    // catch
+	BREAK    = "BREAK"    // break
+	CONTINUE = "CONTINUE" // continue
 )
 
-var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+// Interned single-char literals, indexed by char, so that creating a token doesn't allocate its literal
+var charLiterals [256]string
+
+func init() {
+	for i := range charLiterals {
+		charLiterals[i] = string([]byte{byte(i)})
+	}
 }
 
 func New(tokenType TokenType, ch byte) Token {
-	return Token{Type: tokenType, Literal: string(ch)}
+	return Token{Type: tokenType, Literal: charLiterals[ch]}
+}
+
+var keywords = map[string]TokenType{
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"for":      FOR,
+	"in":       IN,
+	"null":     NULL,
+	"const":    CONST,
+	"switch":   SWITCH,
+	"case":     CASE,
+	"default":  DEFAULT,
+	"import":   IMPORT,
+	"try":      TRY,
+	"catch":    CATCH,
+	"break":    BREAK,
+	"continue": CONTINUE,
+}
+
+// List the spelling of every keyword, in alphabetical order
+func Keywords() []string {
+	spellings := make([]string, 0, len(keywords))
+	for keyword := range keywords {
+		spellings = append(spellings, keyword)
+	}
+
+	sort.Strings(spellings)
+	return spellings
 }
 
-// Get the keyword token corresponding to a multi-char literal
+// Get the keyword token corresponding to a multi-char literal, or IDENT if it isn't a keyword
 func LookupIdent(ident string) TokenType {
-	if tok, ok := keywords[ident]; ok {
-		return tok
+	if tokenType, ok := keywords[ident]; ok {
+		return tokenType
 	}
 	return IDENT
 }
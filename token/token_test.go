@@ -0,0 +1,49 @@
+package token
+
+import "testing"
+
+func TestKeywords(t *testing.T) {
+	for _, keyword := range Keywords() {
+		if tokenType := LookupIdent(keyword); tokenType == IDENT {
+			t.Errorf("Unexpected token type for keyword %q. Expected a keyword type; got %s", keyword, tokenType)
+		}
+	}
+}
+
+func TestLookupIdent(t *testing.T) {
+	tests := []struct {
+		ident    string
+		expected TokenType
+	}{
+		{"fn", FUNCTION},
+		{"let", LET},
+		{"true", TRUE},
+		{"false", FALSE},
+		{"if", IF},
+		{"else", ELSE},
+		{"return", RETURN},
+		{"for", FOR},
+		{"in", IN},
+		{"null", NULL},
+		{"const", CONST},
+		{"switch", SWITCH},
+		{"case", CASE},
+		{"default", DEFAULT},
+		{"import", IMPORT},
+		{"try", TRY},
+		{"catch", CATCH},
+		{"break", BREAK},
+		{"continue", CONTINUE},
+		{"x", IDENT},
+		{"fns", IDENT},
+		{"Let", IDENT},
+		{"breaking", IDENT},
+		{"_", IDENT},
+	}
+
+	for _, test := range tests {
+		if actual := LookupIdent(test.ident); actual != test.expected {
+			t.Errorf("Unexpected token type for %q. Expected %s; got %s", test.ident, test.expected, actual)
+		}
+	}
+}